@@ -17,6 +17,8 @@ import (
 //Services
 var (
 	retryService      *services.RetryService      //Handle retries
+	deliveryService   *services.DeliveryService   //Deliver webhooks to subscribers
+	outboxService     *services.OutboxService     //Hand stored webhooks over to subscribers
 	cleanService      *services.CleanupService    //Handle old webhooks
 	ipRefreshService  *services.IPRefreshService  //Updates external IP
 	usageResetService *services.ResetUsageService //Resets user usage each month
@@ -46,7 +48,13 @@ func startAPI() {
 
 	//Create and init retryService
 	retryService = services.NewRetryService(db, config)
-	retryService.Callback = subCB{retryService: retryService}
+
+	//Create and start the DeliveryService
+	deliveryService = services.NewDeliveryService(db, config)
+	deliveryService.Callback = subCB{retryService: retryService}
+	deliveryService.Start()
+
+	retryService.Scheduler = deliveryService
 	retryService.Start()
 	//TODO load retries from DB
 
@@ -74,8 +82,13 @@ func startAPI() {
 	}
 	log.Debugf("Servers IP address is '%s'\n", ipRefreshService.IP)
 
+	//Create and start the OutboxService
+	outboxService = services.NewOutboxService(db, config)
+	outboxService.Callback = subCB{retryService: retryService}
+	outboxService.Start()
+
 	//Create the APIService and start it
-	apiService = services.NewAPIService(db, config, &ipRefreshService.IP, subCB{retryService: retryService})
+	apiService = services.NewAPIService(db, config, &ipRefreshService.IP, outboxService, subCB{retryService: retryService}, deliveryService)
 	apiService.Start()
 
 	//Startup done
@@ -136,12 +149,12 @@ type subCB struct {
 	retryService *services.RetryService
 }
 
-func (subCB subCB) OnSuccess(subscription models.Subscription) {
-	if retry, has := subCB.retryService.RetryList[subscription.PkID]; has {
-		subCB.retryService.Remove(db, subscription.PkID, retry)
+func (subCB subCB) OnSuccess(subscription models.Subscription, webhook models.Webhook) {
+	//Replays don't replace the retry of a failed webhook
+	if !webhook.IsReplay && subCB.retryService.RemoveSubscription(db, subscription.PkID) {
+		log.Debug("Removing subscription from retryQueue. Reason: successful notification")
 	}
 
-	log.Debug("Removing subscription from retryQueue. Reason: successful notification")
 	if !subscription.IsValid {
 		subscription.TriggerAndValidate(db)
 	} else {
@@ -150,13 +163,14 @@ func (subCB subCB) OnSuccess(subscription models.Subscription) {
 }
 
 func (subCB subCB) OnError(subscription models.Subscription, source models.Source, webhook models.Webhook) {
-	subCB.retryService.Add(db, subscription.PkID, source.PkID, webhook.PkID)
+	subCB.retryService.Add(db, subscription, source.PkID, webhook.PkID)
 }
 
 func (subCB subCB) OnUnsubscribe(subscription models.Subscription) {
 	subscription.Remove(db)
 }
 
-func (subCB subCB) OnWebhookReceive(webhook *models.Webhook, source *models.Source) {
-	models.NotifyAllSubscriber(db, config, webhook, source, subCB)
+func (subCB subCB) OnWebhookReceive(webhook *models.Webhook, source *models.Source) error {
+	_, err := models.NotifyAllSubscriber(db, deliveryService, webhook, source)
+	return err
 }
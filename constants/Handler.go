@@ -2,3 +2,9 @@ package constants
 
 //DefaultMaxPayloadSize the default max payload size
 const DefaultMaxPayloadSize = uint(150)
+
+//DefaultHistoryPageSize the default count of webhooks per history page
+const DefaultHistoryPageSize = 50
+
+//MaxHistoryPageSize the max count of webhooks per history page
+const MaxHistoryPageSize = 200
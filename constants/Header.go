@@ -7,4 +7,26 @@ const (
 	HeaderSource = "W_S_Source"
 	//HeaderReceived the unix time when the hook was received
 	HeaderReceived = "W_S_Source"
+	//HeaderReplay set if the webhook is replayed
+	HeaderReplay = "W_S_Replay"
+	//HeaderTimestamp the unix time the delivery was signed at
+	HeaderTimestamp = "W_S_Timestamp"
+	//HeaderSignature the HMAC-SHA256 signatures of the delivery
+	HeaderSignature = "W_S_Signature"
 )
+
+//HeaderIdempotencyKey generic header containing a unique ID of the delivery
+const HeaderIdempotencyKey = "Idempotency-Key"
+
+//HopByHopHeaders headers which are only meaningful for a single connection and are never forwarded
+var HopByHopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"TE",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
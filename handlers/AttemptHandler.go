@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"net/http"
+
+	dbhelper "github.com/JojiiOfficial/GoDBHelper"
+	"github.com/JojiiOfficial/WhShareServer/constants"
+	"github.com/JojiiOfficial/WhShareServer/models"
+)
+
+//ListSourceAttempts lists the delivery attempts of all subscriptions of a source
+//-> /source/attempts
+func ListSourceAttempts(db *dbhelper.DBhelper, handlerData handlerData, w http.ResponseWriter, r *http.Request) {
+	var request models.DeliveryAttemptsRequest
+	if !parseUserInput(handlerData.config, w, r, &request) {
+		return
+	}
+
+	if len(request.SourceID) != 32 || request.Limit < 0 || request.Limit > constants.MaxHistoryPageSize {
+		sendError("input missing wrong length", w, models.WrongInputFormatError, http.StatusUnprocessableEntity)
+		return
+	}
+
+	source := getOwnSource(db, handlerData, w, request.SourceID)
+	if source == nil {
+		return
+	}
+
+	sendDeliveryAttempts(db, w, models.DeliveryAttemptFilter{
+		SourcePK:  source.PkID,
+		WebhookPK: request.WebhookID,
+		Cursor:    request.Cursor,
+		Limit:     request.Limit,
+	})
+}
+
+//ListSubscriptionAttempts lists the delivery attempts of a subscription
+//-> /sub/attempts
+func ListSubscriptionAttempts(db *dbhelper.DBhelper, handlerData handlerData, w http.ResponseWriter, r *http.Request) {
+	var request models.DeliveryAttemptsRequest
+	if !parseUserInput(handlerData.config, w, r, &request) {
+		return
+	}
+
+	if len(request.SubscriptionID) != 32 || request.Limit < 0 || request.Limit > constants.MaxHistoryPageSize {
+		sendError("input missing wrong length", w, models.WrongInputFormatError, http.StatusUnprocessableEntity)
+		return
+	}
+
+	subscription, err := models.GetSubscriptionBySubsID(db, request.SubscriptionID)
+	if err != nil {
+		if err.Error() == dbhelper.ErrNoRowsInResultSet {
+			sendResponse(w, models.ResponseError, models.NotFoundError, nil, http.StatusNotFound)
+			return
+		}
+
+		sendServerError(w)
+		return
+	}
+
+	//Show only if it's users subscription or user not logged in and subscriptionID matches
+	if handlerData.user != nil && subscription.UserID != handlerData.user.Pkid {
+		sendResponse(w, models.ResponseError, models.ActionNotAllowed, nil, http.StatusForbidden)
+		return
+	}
+
+	sendDeliveryAttempts(db, w, models.DeliveryAttemptFilter{
+		SubscriptionPK: subscription.PkID,
+		WebhookPK:      request.WebhookID,
+		Cursor:         request.Cursor,
+		Limit:          request.Limit,
+	})
+}
+
+//sendDeliveryAttempts sends a page of delivery attempts matching the filter
+func sendDeliveryAttempts(db *dbhelper.DBhelper, w http.ResponseWriter, filter models.DeliveryAttemptFilter) {
+	if filter.Limit == 0 {
+		filter.Limit = constants.DefaultHistoryPageSize
+	}
+
+	attempts, err := models.GetDeliveryAttempts(db, filter)
+	if err != nil {
+		LogError(err)
+		sendServerError(w)
+		return
+	}
+
+	response := models.DeliveryAttemptsResponse{
+		Attempts: attempts,
+	}
+
+	if response.Attempts == nil {
+		response.Attempts = []models.DeliveryAttempt{}
+	}
+
+	//More attempts might be available if the page is full
+	if len(attempts) == filter.Limit {
+		response.NextCursor = attempts[len(attempts)-1].PkID
+	}
+
+	sendResponse(w, models.ResponseSuccess, "", response)
+}
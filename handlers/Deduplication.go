@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/JojiiOfficial/WhShareServer/constants"
+	"github.com/JojiiOfficial/WhShareServer/models"
+	"github.com/JojiiOfficial/WhShareServer/modes"
+)
+
+//getDeliveryID returns the ID of the delivery sent by the provider. If the provider doesn't
+//send an ID and the source opted in, the hash of the method, query and payload is used.
+//Returns an empty string if the webhook shouldn't be deduplicated
+func getDeliveryID(source *models.Source, r *http.Request, payload []byte) string {
+	var deliveryID string
+	if m, has := modes.Get(source.Mode); has {
+		deliveryID = m.DeliveryID(r.Header)
+	}
+
+	if len(deliveryID) == 0 {
+		deliveryID = r.Header.Get(constants.HeaderIdempotencyKey)
+	}
+
+	deliveryID = strings.TrimSpace(deliveryID)
+	if len(deliveryID) > 0 && len(deliveryID) <= 120 {
+		return deliveryID
+	}
+
+	if !source.HashDeduplication {
+		return ""
+	}
+
+	hash := sha256.New()
+	hash.Write([]byte(r.Method + "\n" + r.URL.RawQuery + "\n"))
+	hash.Write(payload)
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil))
+}
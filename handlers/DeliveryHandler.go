@@ -0,0 +1,19 @@
+package handlers
+
+import (
+	"net/http"
+
+	dbhelper "github.com/JojiiOfficial/GoDBHelper"
+	"github.com/JojiiOfficial/WhShareServer/models"
+)
+
+//GetDeliveryStats returns the state of the delivery queue
+//-> /admin/deliveries
+func GetDeliveryStats(db *dbhelper.DBhelper, handlerData handlerData, w http.ResponseWriter, r *http.Request) {
+	if !handlerData.user.IsAdmin() {
+		sendResponse(w, models.ResponseError, models.ActionNotAllowed, nil, http.StatusForbidden)
+		return
+	}
+
+	sendResponse(w, models.ResponseSuccess, "", handlerData.scheduler.Stats())
+}
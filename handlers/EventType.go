@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/JojiiOfficial/WhShareServer/models"
+	"github.com/JojiiOfficial/WhShareServer/modes"
+)
+
+//getEventType extracts the normalized event type of a webhook depending on the sources mode
+func getEventType(mode uint8, header http.Header, payload []byte) string {
+	m, has := modes.Get(mode)
+	if !has {
+		return ""
+	}
+
+	return models.NormalizeEventType(m.EventType(header, payload))
+}
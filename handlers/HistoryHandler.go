@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	dbhelper "github.com/JojiiOfficial/GoDBHelper"
+	"github.com/JojiiOfficial/WhShareServer/constants"
+	"github.com/JojiiOfficial/WhShareServer/models"
+)
+
+//ListWebhooks lists the webhooks a source received
+//-> /source/webhooks
+func ListWebhooks(db *dbhelper.DBhelper, handlerData handlerData, w http.ResponseWriter, r *http.Request) {
+	var request models.WebhookListRequest
+	if !parseUserInput(handlerData.config, w, r, &request) {
+		return
+	}
+
+	if len(request.SourceID) != 32 || request.Limit < 0 || request.Limit > constants.MaxHistoryPageSize {
+		sendError("input missing wrong length", w, models.WrongInputFormatError, http.StatusUnprocessableEntity)
+		return
+	}
+
+	if checkPayloadSizes(w, constants.DefaultMaxPayloadSize*10, request.EventType) {
+		return
+	}
+
+	source := getOwnSource(db, handlerData, w, request.SourceID)
+	if source == nil {
+		return
+	}
+
+	filter := models.WebhookFilter{
+		Cursor: request.Cursor,
+		Limit:  request.Limit,
+		From:   request.From,
+		To:     request.To,
+	}
+
+	if filter.Limit == 0 {
+		filter.Limit = constants.DefaultHistoryPageSize
+	}
+
+	for _, eventType := range strings.Split(request.EventType, ",") {
+		if eventType = models.NormalizeEventType(eventType); len(eventType) > 0 {
+			filter.EventTypes = append(filter.EventTypes, eventType)
+		}
+	}
+
+	webhooks, err := models.GetWebhooksOfSource(db, source.PkID, filter)
+	if err != nil {
+		LogError(err)
+		sendServerError(w)
+		return
+	}
+
+	response := models.WebhookListResponse{
+		Webhooks: []models.WebhookInfo{},
+	}
+
+	for _, webhook := range webhooks {
+		response.Webhooks = append(response.Webhooks, webhook.GetInfo())
+	}
+
+	//More webhooks might be available if the page is full
+	if len(webhooks) == filter.Limit {
+		response.NextCursor = webhooks[len(webhooks)-1].PkID
+	}
+
+	sendResponse(w, models.ResponseSuccess, "", response)
+}
+
+//GetWebhook returns a webhook a source received including its headers and payload
+//-> /source/webhook
+func GetWebhook(db *dbhelper.DBhelper, handlerData handlerData, w http.ResponseWriter, r *http.Request) {
+	var request models.WebhookRequest
+	if !parseUserInput(handlerData.config, w, r, &request) {
+		return
+	}
+
+	if len(request.SourceID) != 32 || request.WebhookID == 0 {
+		sendError("input missing wrong length", w, models.WrongInputFormatError, http.StatusUnprocessableEntity)
+		return
+	}
+
+	source := getOwnSource(db, handlerData, w, request.SourceID)
+	if source == nil {
+		return
+	}
+
+	webhook, err := models.GetWebhookByPK(db, request.WebhookID)
+	if err != nil || webhook.SourceID != source.PkID {
+		sendResponse(w, models.ResponseError, models.NotFoundError, nil, http.StatusNotFound)
+		return
+	}
+
+	sendResponse(w, models.ResponseSuccess, "", models.WebhookResponse{
+		WebhookInfo: webhook.GetInfo(),
+		Query:       webhook.Query,
+		Headers:     webhook.GetHeader(),
+		Payload:     webhook.Payload,
+	})
+}
+
+//getOwnSource returns the source if it was created by the user. Otherwise an error is sent and nil returned
+func getOwnSource(db *dbhelper.DBhelper, handlerData handlerData, w http.ResponseWriter, sourceID string) *models.Source {
+	source, err := models.GetSourceFromSourceID(db, sourceID)
+	if err != nil {
+		if err.Error() == dbhelper.ErrNoRowsInResultSet {
+			sendResponse(w, models.ResponseError, models.NotFoundError, nil, http.StatusNotFound)
+			return nil
+		}
+
+		LogError(err)
+		sendServerError(w)
+		return nil
+	}
+
+	if source.CreatorID != handlerData.user.Pkid {
+		sendError("user not allowed", w, models.ActionNotAllowed, http.StatusForbidden)
+		return nil
+	}
+
+	return source
+}
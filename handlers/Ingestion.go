@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"sort"
+	"strings"
+
+	gaw "github.com/JojiiOfficial/GoAw"
+	"github.com/JojiiOfficial/WhShareServer/models"
+	"github.com/JojiiOfficial/WhShareServer/modes"
+)
+
+//ErrInvalidJSON error if a payload is declared as JSON but can't be parsed
+var ErrInvalidJSON = errors.New("invalid JSON payload")
+
+//processPayload removes the blocked items and appends the params to the payload.
+//JSON objects and form bodies are processed, every other payload stays unchanged
+func processPayload(contentType string, payload []byte, removeItems []string, params []string) ([]byte, error) {
+	switch models.GetPayloadKind(contentType, payload) {
+	case models.JSONPayload:
+		return processJSONPayload(payload, removeItems, params)
+	case models.FormPayload:
+		return processFormPayload(payload, removeItems, params)
+	}
+
+	return payload, nil
+}
+
+func processJSONPayload(payload []byte, removeItems []string, params []string) ([]byte, error) {
+	var parsed interface{}
+	if err := json.Unmarshal(payload, &parsed); err != nil {
+		return nil, ErrInvalidJSON
+	}
+
+	//Only JSON objects can be filtered or extended
+	if _, ok := parsed.(map[string]interface{}); !ok {
+		return payload, nil
+	}
+
+	//Delete in config specified json objects
+	payload, err := gaw.JSONRemoveItems(payload, removeItems, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(params) > 0 {
+		return appendJSONKeys(payload, params...)
+	}
+
+	return payload, nil
+}
+
+//processFormPayload keeps the order of the fields. Params replace existing fields or get appended
+func processFormPayload(payload []byte, removeItems []string, params []string) ([]byte, error) {
+	if len(removeItems) == 0 && len(params) == 0 {
+		return payload, nil
+	}
+
+	parsedParams := parseParams(params)
+	setParams := make(map[string]bool)
+
+	payload, err := models.EditForm(payload, func(key, value string) (string, bool) {
+		if gaw.IsInStringArray(key, removeItems) {
+			return value, false
+		}
+
+		if param, has := parsedParams[key]; has {
+			//Only keep the first field of a param
+			if setParams[key] {
+				return value, false
+			}
+
+			setParams[key] = true
+			return param, true
+		}
+
+		return value, true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	//Append params which aren't in the payload yet
+	var missing []string
+	for key := range parsedParams {
+		if !setParams[key] {
+			missing = append(missing, key)
+		}
+	}
+	sort.Strings(missing)
+
+	for _, key := range missing {
+		field := url.QueryEscape(key) + "=" + url.QueryEscape(parsedParams[key])
+		if len(payload) > 0 {
+			field = "&" + field
+		}
+		payload = append(payload, field...)
+	}
+
+	return payload, nil
+}
+
+//getJSONFilters returns the JSON objects to remove from payloads of the mode
+func getJSONFilters(config *models.ConfigStruct, mode uint8) []string {
+	m, has := modes.Get(mode)
+	if !has {
+		return nil
+	}
+
+	filters := append([]string{}, m.DefaultFilters()...)
+	return append(filters, config.Server.WebhookBlacklist.JSONObjects[m.Name()]...)
+}
+
+//parseParams parses key value pairs separated by =
+func parseParams(kvpairs []string) map[string]string {
+	params := make(map[string]string)
+
+	for _, kvpair := range kvpairs {
+		if !strings.Contains(kvpair, "=") {
+			continue
+		}
+
+		splitted := strings.Split(kvpair, "=")
+		params[splitted[0]] = strings.Join(splitted[1:], "=")
+	}
+
+	return params
+}
@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"net/http"
+
+	dbhelper "github.com/JojiiOfficial/GoDBHelper"
+	"github.com/JojiiOfficial/WhShareServer/models"
+	"github.com/JojiiOfficial/WhShareServer/modes"
+)
+
+//ListModes lists all available source modes
+//-> /modes
+func ListModes(db *dbhelper.DBhelper, handlerData handlerData, w http.ResponseWriter, r *http.Request) {
+	var response models.ModeListResponse
+
+	for _, mode := range modes.All() {
+		response.Modes = append(response.Modes, models.ModeResponse{
+			ID:   mode.ID(),
+			Name: mode.Name(),
+		})
+	}
+
+	sendResponse(w, models.ResponseSuccess, "", response)
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+//Buckets which weren't used for this time get removed
+const rateLimiterIdleTime = 10 * time.Minute
+
+//rateLimiter token bucket rate limiter for multiple keys
+type rateLimiter struct {
+	mutex       sync.Mutex
+	buckets     map[string]*tokenBucket
+	maxBuckets  int
+	lastCleanup time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+//A maxBuckets value < 1 disables the limit of buckets
+func newRateLimiter(maxBuckets int) *rateLimiter {
+	return &rateLimiter{
+		buckets:     make(map[string]*tokenBucket),
+		maxBuckets:  maxBuckets,
+		lastCleanup: time.Now(),
+	}
+}
+
+//allow takes a token from the bucket of key. The bucket gets refilled with perMinute tokens
+//per minute and can hold burst tokens. Returns false and the time to wait if no token is left.
+//A perMinute value < 1 disables the limit
+func (limiter *rateLimiter) allow(key string, perMinute, burst int) (bool, time.Duration) {
+	if perMinute < 1 {
+		return true, 0
+	}
+
+	if burst < 1 {
+		burst = 1
+	}
+
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	now := time.Now()
+	limiter.cleanup(now)
+
+	bucket, has := limiter.buckets[key]
+	if !has {
+		limiter.makeRoom()
+		bucket = &tokenBucket{
+			tokens: float64(burst),
+			last:   now,
+		}
+		limiter.buckets[key] = bucket
+	}
+
+	//Refill the bucket
+	rate := float64(perMinute) / 60
+	bucket.tokens = math.Min(float64(burst), bucket.tokens+now.Sub(bucket.last).Seconds()*rate)
+	bucket.last = now
+
+	if bucket.tokens < 1 {
+		wait := time.Duration((1 - bucket.tokens) / rate * float64(time.Second))
+		return false, wait
+	}
+
+	bucket.tokens--
+	return true, 0
+}
+
+//Remove unused buckets. Must be called while the mutex is locked
+func (limiter *rateLimiter) cleanup(now time.Time) {
+	if now.Sub(limiter.lastCleanup) < rateLimiterIdleTime {
+		return
+	}
+
+	for key, bucket := range limiter.buckets {
+		if now.Sub(bucket.last) >= rateLimiterIdleTime {
+			delete(limiter.buckets, key)
+		}
+	}
+
+	limiter.lastCleanup = now
+}
+
+//Remove the least recently used bucket if the limit of buckets is reached.
+//Must be called while the mutex is locked
+func (limiter *rateLimiter) makeRoom() {
+	if limiter.maxBuckets < 1 || len(limiter.buckets) < limiter.maxBuckets {
+		return
+	}
+
+	var oldestKey string
+	var oldest time.Time
+	for key, bucket := range limiter.buckets {
+		if len(oldestKey) == 0 || bucket.last.Before(oldest) {
+			oldestKey = key
+			oldest = bucket.last
+		}
+	}
+
+	delete(limiter.buckets, oldestKey)
+}
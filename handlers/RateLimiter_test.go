@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiterAllow(t *testing.T) {
+	tests := []struct {
+		name      string
+		perMinute int
+		burst     int
+		requests  int
+		allowed   int
+	}{
+		{"disabled", 0, 0, 10, 10},
+		{"burst", 60, 3, 5, 3},
+		{"burst below 1", 60, 0, 3, 1},
+		{"single", 1, 1, 2, 1},
+	}
+
+	for _, test := range tests {
+		limiter := newRateLimiter(0)
+
+		var allowed int
+		for i := 0; i < test.requests; i++ {
+			ok, wait := limiter.allow("key", test.perMinute, test.burst)
+			if ok {
+				allowed++
+				if wait != 0 {
+					t.Errorf("%s: expected no wait time for an allowed request, got %s", test.name, wait)
+				}
+			} else if wait <= 0 {
+				t.Errorf("%s: expected a wait time for a denied request", test.name)
+			}
+		}
+
+		if allowed != test.allowed {
+			t.Errorf("%s: expected %d allowed requests, got %d", test.name, test.allowed, allowed)
+		}
+	}
+}
+
+func TestRateLimiterRefill(t *testing.T) {
+	tests := []struct {
+		name    string
+		elapsed time.Duration
+		tokens  float64
+		allowed bool
+	}{
+		{"empty", 0, 0, false},
+		{"half token", 500 * time.Millisecond, 0, false},
+		{"one token", time.Second, 0, true},
+		{"capped at burst", time.Hour, 2, true},
+	}
+
+	for _, test := range tests {
+		limiter := newRateLimiter(0)
+		limiter.buckets["key"] = &tokenBucket{
+			tokens: test.tokens,
+			last:   time.Now().Add(-test.elapsed),
+		}
+
+		allowed, wait := limiter.allow("key", 60, 2)
+		if allowed != test.allowed {
+			t.Errorf("%s: expected allowed=%t, got %t", test.name, test.allowed, allowed)
+		}
+
+		if !allowed && (wait <= 0 || wait > time.Second) {
+			t.Errorf("%s: expected a wait time up to 1s, got %s", test.name, wait)
+		}
+
+		if tokens := limiter.buckets["key"].tokens; tokens > 2 {
+			t.Errorf("%s: bucket holds %f tokens, more than its burst", test.name, tokens)
+		}
+	}
+}
+
+func TestRateLimiterKeys(t *testing.T) {
+	limiter := newRateLimiter(0)
+
+	if ok, _ := limiter.allow("a", 1, 1); !ok {
+		t.Fatal("expected first request of a to be allowed")
+	}
+	if ok, _ := limiter.allow("a", 1, 1); ok {
+		t.Error("expected second request of a to be denied")
+	}
+	if ok, _ := limiter.allow("b", 1, 1); !ok {
+		t.Error("expected buckets to be separated by key")
+	}
+}
+
+func TestRateLimiterMaxBuckets(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxBuckets int
+		keys       []string
+		remaining  []string
+	}{
+		{"unlimited", 0, []string{"a", "b", "c"}, []string{"a", "b", "c"}},
+		{"evict least recently used", 2, []string{"a", "b", "c"}, []string{"b", "c"}},
+		{"reuse existing bucket", 2, []string{"a", "b", "a"}, []string{"a", "b"}},
+	}
+
+	for _, test := range tests {
+		limiter := newRateLimiter(test.maxBuckets)
+
+		for i, key := range test.keys {
+			limiter.allow(key, 60, 10)
+			//Keep the usage order of the buckets distinct
+			limiter.buckets[key].last = limiter.buckets[key].last.Add(time.Duration(i-len(test.keys)) * time.Millisecond)
+		}
+
+		if len(limiter.buckets) != len(test.remaining) {
+			t.Errorf("%s: expected %d buckets, got %d", test.name, len(test.remaining), len(limiter.buckets))
+		}
+
+		for _, key := range test.remaining {
+			if _, has := limiter.buckets[key]; !has {
+				t.Errorf("%s: expected bucket %s to be kept", test.name, key)
+			}
+		}
+	}
+}
+
+func TestRateLimiterCleanup(t *testing.T) {
+	limiter := newRateLimiter(0)
+	now := time.Now()
+
+	limiter.buckets["idle"] = &tokenBucket{last: now.Add(-rateLimiterIdleTime)}
+	limiter.buckets["active"] = &tokenBucket{last: now.Add(-time.Minute)}
+	limiter.lastCleanup = now.Add(-rateLimiterIdleTime)
+
+	limiter.cleanup(now)
+
+	if _, has := limiter.buckets["idle"]; has {
+		t.Error("expected idle bucket to be removed")
+	}
+	if _, has := limiter.buckets["active"]; !has {
+		t.Error("expected active bucket to be kept")
+	}
+}
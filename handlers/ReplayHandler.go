@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	dbhelper "github.com/JojiiOfficial/GoDBHelper"
+	"github.com/JojiiOfficial/WhShareServer/models"
+)
+
+//ReplayWebhook sends a stored webhook again to all subscriptions or a single one
+//-> /source/replay
+func ReplayWebhook(db *dbhelper.DBhelper, handlerData handlerData, w http.ResponseWriter, r *http.Request) {
+	var request models.WebhookReplayRequest
+	if !parseUserInput(handlerData.config, w, r, &request) {
+		return
+	}
+
+	if len(request.SourceID) != 32 || request.WebhookID == 0 {
+		sendError("input missing wrong length", w, models.WrongInputFormatError, http.StatusUnprocessableEntity)
+		return
+	}
+
+	source := getOwnSource(db, handlerData, w, request.SourceID)
+	if source == nil {
+		return
+	}
+
+	if source.IsPaused {
+		sendResponse(w, models.ResponseError, "Source is paused", nil, http.StatusConflict)
+		return
+	}
+
+	webhook, err := models.GetWebhookByPK(db, request.WebhookID)
+	if err != nil || webhook.SourceID != source.PkID {
+		sendResponse(w, models.ResponseError, models.NotFoundError, nil, http.StatusNotFound)
+		return
+	}
+	webhook.IsReplay = true
+
+	//Replay to all subscriptions
+	if len(request.SubscriptionID) == 0 {
+		count, err := models.NotifyAllSubscriber(db, handlerData.scheduler, webhook, source)
+		if err != nil {
+			sendScheduleError(w, err)
+			return
+		}
+		sendResponse(w, models.ResponseSuccess, "", models.ReplayResponse{Count: count})
+		return
+	}
+
+	subscription, err := models.GetSubscriptionBySubsID(db, request.SubscriptionID)
+	if err != nil || subscription.Source != source.PkID {
+		sendResponse(w, models.ResponseError, "Subscription not found", nil, http.StatusNotFound)
+		return
+	}
+
+	err = handlerData.scheduler.Schedule(models.Delivery{
+		Webhook:      webhook,
+		Source:       source,
+		Subscription: subscription,
+		Ordered:      subscription.Ordered,
+	})
+	if err != nil {
+		sendScheduleError(w, err)
+		return
+	}
+
+	sendResponse(w, models.ResponseSuccess, "", models.ReplayResponse{Count: 1})
+}
+
+//ReplaySubscription sends webhooks the subscription received again
+//-> /sub/replay
+func ReplaySubscription(db *dbhelper.DBhelper, handlerData handlerData, w http.ResponseWriter, r *http.Request) {
+	var request models.SubscriptionReplayRequest
+	if !parseUserInput(handlerData.config, w, r, &request) {
+		return
+	}
+
+	if len(request.SubscriptionID) != 32 || (request.WebhookID == 0 && len(request.Since) == 0) {
+		sendError("input missing wrong length", w, models.WrongInputFormatError, http.StatusUnprocessableEntity)
+		return
+	}
+
+	subscription, err := models.GetSubscriptionBySubsID(db, request.SubscriptionID)
+	if err != nil {
+		if err.Error() == dbhelper.ErrNoRowsInResultSet {
+			sendResponse(w, models.ResponseError, models.NotFoundError, nil, http.StatusNotFound)
+			return
+		}
+
+		sendServerError(w)
+		return
+	}
+
+	//Replay only if it's users subscription or user not logged in and subscriptionID matches
+	if handlerData.user != nil && subscription.UserID != handlerData.user.Pkid {
+		sendResponse(w, models.ResponseError, models.ActionNotAllowed, nil, http.StatusForbidden)
+		return
+	}
+
+	source, err := models.GetSourceByPK(db, subscription.Source)
+	if err != nil {
+		sendServerError(w)
+		return
+	}
+
+	if source.IsPaused {
+		sendResponse(w, models.ResponseError, "Source is paused", nil, http.StatusConflict)
+		return
+	}
+
+	//Subscribers can only replay webhooks received while they were subscribed
+	minTime := subscription.Time
+	if oldest := time.Now().Add(-handlerData.config.Server.MaxReplayAge); oldest.After(minTime) {
+		minTime = oldest
+	}
+
+	var webhooks []models.Webhook
+	if request.WebhookID > 0 {
+		webhook, err := models.GetWebhookByPK(db, request.WebhookID)
+		if err != nil || webhook.SourceID != source.PkID || webhook.Received.Before(minTime) {
+			sendResponse(w, models.ResponseError, models.NotFoundError, nil, http.StatusNotFound)
+			return
+		}
+		webhooks = []models.Webhook{*webhook}
+	} else {
+		since, err := time.ParseDuration(request.Since)
+		if err != nil || since <= 0 {
+			sendResponse(w, models.ResponseError, "Invalid duration", nil, http.StatusUnprocessableEntity)
+			return
+		}
+
+		if t := time.Now().Add(-since); t.After(minTime) {
+			minTime = t
+		}
+
+		webhooks, err = models.GetWebhooksSince(db, source.PkID, minTime, handlerData.config.Server.MaxReplayCount)
+		if err != nil {
+			LogError(err)
+			sendServerError(w)
+			return
+		}
+	}
+
+	//Replay only webhooks the subscription would have received
+	var accepted []models.Webhook
+	for i := range webhooks {
+		if webhooks[i].IsAcceptedBy(*subscription) {
+			webhooks[i].IsReplay = true
+			accepted = append(accepted, webhooks[i])
+		}
+	}
+
+	//Keep the order of the webhooks
+	deliveries := make([]models.Delivery, len(accepted))
+	for i := range accepted {
+		deliveries[i] = models.Delivery{
+			Webhook:      &accepted[i],
+			Source:       source,
+			Subscription: subscription,
+			Ordered:      true,
+		}
+	}
+
+	if err = handlerData.scheduler.Schedule(deliveries...); err != nil {
+		sendScheduleError(w, err)
+		return
+	}
+
+	sendResponse(w, models.ResponseSuccess, "", models.ReplayResponse{Count: len(accepted)})
+}
+
+//sendScheduleError sends 503 if the delivery queue is full
+func sendScheduleError(w http.ResponseWriter, err error) {
+	if err == models.ErrQueueFull {
+		w.Header().Set("Retry-After", "10")
+		sendResponse(w, models.ResponseError, "Delivery queue full. Try again later", nil, http.StatusServiceUnavailable)
+		return
+	}
+
+	LogError(err)
+	sendServerError(w)
+}
@@ -16,7 +16,10 @@ type handlerData struct {
 	config             *models.ConfigStruct
 	ownIP              *string
 	user               *models.User
-	subscriberCallback models.SubscriberNotifyCallback
+	subscriberCallback models.Outbox
+	notifyCallback     models.NotifyCallback
+	scheduler          models.DeliveryScheduler
+	rateLimiter        *rateLimiter
 }
 
 //Route for REST
@@ -86,6 +89,34 @@ var (
 			HandlerFunc: UpdateSource,
 			HandlerType: sessionRequest,
 		},
+		Route{
+			Name:        "replay webhook",
+			Pattern:     "/source/replay",
+			Method:      POSTMethod,
+			HandlerFunc: ReplayWebhook,
+			HandlerType: sessionRequest,
+		},
+		Route{
+			Name:        "list webhooks",
+			Pattern:     "/source/webhooks",
+			Method:      POSTMethod,
+			HandlerFunc: ListWebhooks,
+			HandlerType: sessionRequest,
+		},
+		Route{
+			Name:        "get webhook",
+			Pattern:     "/source/webhook",
+			Method:      POSTMethod,
+			HandlerFunc: GetWebhook,
+			HandlerType: sessionRequest,
+		},
+		Route{
+			Name:        "source delivery attempts",
+			Pattern:     "/source/attempts",
+			Method:      POSTMethod,
+			HandlerFunc: ListSourceAttempts,
+			HandlerType: sessionRequest,
+		},
 		Route{
 			Name:        "list sources",
 			Pattern:     "/sources",
@@ -116,8 +147,51 @@ var (
 			HandlerFunc: UpdateCallbackURL,
 			HandlerType: optionalTokenRequest,
 		},
+		Route{
+			Name:        "replay subscription",
+			Pattern:     "/sub/replay",
+			Method:      POSTMethod,
+			HandlerFunc: ReplaySubscription,
+			HandlerType: optionalTokenRequest,
+		},
+		Route{
+			Name:        "subscription delivery attempts",
+			Pattern:     "/sub/attempts",
+			Method:      POSTMethod,
+			HandlerFunc: ListSubscriptionAttempts,
+			HandlerType: optionalTokenRequest,
+		},
+		Route{
+			Name:        "update subscription",
+			Pattern:     "/sub/update/{action}",
+			Method:      POSTMethod,
+			HandlerFunc: UpdateSubscription,
+			HandlerType: optionalTokenRequest,
+		},
+
+		//Modes
+		Route{
+			Name:        "list modes",
+			Pattern:     "/modes",
+			Method:      GetMethod,
+			HandlerFunc: ListModes,
+			HandlerType: defaultRequest,
+		},
+
+		//Admin
+		Route{
+			Name:        "delivery stats",
+			Pattern:     "/admin/deliveries",
+			Method:      GetMethod,
+			HandlerFunc: GetDeliveryStats,
+			HandlerType: sessionRequest,
+		},
 
 		//Webhooks
+		//Without secret. Verified by the signature of the provider
+		Route{"Post webhook signed", "POST", "/webhook/post/{sourceID}", WebhookHandler, defaultRequest},
+		Route{"GET webhook signed", "GET", "/webhook/get/{sourceID}", WebhookHandler, defaultRequest},
+		//With secret
 		Route{"Post webhook", "POST", "/webhook/post/{sourceID}/{secret}", WebhookHandler, defaultRequest},
 		Route{"GET webhook", "GET", "/webhook/get/{sourceID}/{secret}", WebhookHandler, defaultRequest},
 		//With params
@@ -127,8 +201,10 @@ var (
 )
 
 //NewRouter create new router
-func NewRouter(db *dbhelper.DBhelper, config *models.ConfigStruct, ownIP *string, callback models.SubscriberNotifyCallback) *mux.Router {
+func NewRouter(db *dbhelper.DBhelper, config *models.ConfigStruct, ownIP *string, callback models.Outbox, notifyCallback models.NotifyCallback, scheduler models.DeliveryScheduler) *mux.Router {
 	router := mux.NewRouter().StrictSlash(true)
+	limiter := newRateLimiter(config.Server.RateLimit.MaxBuckets)
+
 	for _, route := range routes {
 		router.
 			Methods(string(route.Method)).
@@ -137,7 +213,10 @@ func NewRouter(db *dbhelper.DBhelper, config *models.ConfigStruct, ownIP *string
 			Handler(RouteHandler(db, route.HandlerType, &handlerData{
 				config:             config,
 				subscriberCallback: callback,
+				notifyCallback:     notifyCallback,
+				scheduler:          scheduler,
 				ownIP:              ownIP,
+				rateLimiter:        limiter,
 			}, route.HandlerFunc, route.Name))
 	}
 	return router
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/JojiiOfficial/WhShareServer/models"
+	"github.com/JojiiOfficial/WhShareServer/modes"
+)
+
+//verifyWebhook checks if the webhook was sent by the owner of the source.
+//If the secret is passed in the URL, it gets compared with the sources secrets.
+//Otherwise the proof of the sources provider is verified
+func verifyWebhook(source *models.Source, urlSecret string, header http.Header, payload []byte) error {
+	secrets := source.GetValidSecrets()
+
+	if len(urlSecret) > 0 {
+		if !modes.MatchesAnySecret(urlSecret, secrets) {
+			return modes.ErrInvalidSignature
+		}
+		return nil
+	}
+
+	mode, has := modes.Get(source.Mode)
+	if !has {
+		return modes.ErrMissingSignature
+	}
+
+	return mode.VerifySignature(header, payload, secrets)
+}
@@ -2,10 +2,15 @@ package handlers
 
 import (
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	gaw "github.com/JojiiOfficial/GoAw"
 	dbhelper "github.com/JojiiOfficial/GoDBHelper"
+	"github.com/JojiiOfficial/WhShareServer/constants"
 	"github.com/JojiiOfficial/WhShareServer/models"
+	"github.com/JojiiOfficial/WhShareServer/modes"
 	"github.com/gorilla/mux"
 )
 
@@ -22,6 +27,11 @@ func CreateSource(db *dbhelper.DBhelper, handlerData handlerData, w http.Respons
 		return
 	}
 
+	if _, has := modes.Get(request.Mode); !has {
+		sendResponse(w, models.ResponseError, "Invalid mode", nil, http.StatusUnprocessableEntity)
+		return
+	}
+
 	//Check if user is allowed to create sources
 	if !handlerData.user.CanCreateSource(request.Private) {
 		sendResponse(w, models.ResponseError, "You are not allowed to have this kind of source", nil, http.StatusForbidden)
@@ -97,6 +107,13 @@ func ListSources(db *dbhelper.DBhelper, handlerData handlerData, w http.Response
 			return
 		}
 
+		for i := range sources {
+			if err = sources[i].LoadEventTypes(db); err != nil {
+				sendServerError(w)
+				return
+			}
+		}
+
 		response = models.ListSourcesResponse{
 			Sources: sources,
 		}
@@ -109,9 +126,19 @@ func ListSources(db *dbhelper.DBhelper, handlerData handlerData, w http.Response
 			return
 		}
 
-		if handlerData.user.Pkid != source.CreatorID {
+		if handlerData.user.Pkid == source.CreatorID {
+			if err = source.LoadEventTypes(db); err != nil {
+				sendServerError(w)
+				return
+			}
+		} else {
 			source.CreatorID = 0
 			source.Secret = ""
+			source.Duplicates = 0
+			source.AllowedIPs = ""
+			source.Redaction = ""
+			source.JSONSchema = ""
+			source.ValidationFailures = 0
 			if source.IsPrivate {
 				source.Description = "This is a private source"
 				source.Name = "Private"
@@ -136,6 +163,21 @@ func UpdateSource(db *dbhelper.DBhelper, handlerData handlerData, w http.Respons
 		"changedescr",
 		"rename",
 		"toggleAccess",
+		"rotateSecret",
+		"allowedIPs",
+		"redaction",
+		"sync",
+		"pause",
+		"resume",
+		"jsonSchema",
+		"hashDedup",
+	}
+
+	//Actions which accept content larger than the default max payload size
+	largeContentActions := []string{
+		"allowedIPs",
+		"redaction",
+		"jsonSchema",
 	}
 
 	if !gaw.IsInStringArray(action, actions) {
@@ -148,7 +190,16 @@ func UpdateSource(db *dbhelper.DBhelper, handlerData handlerData, w http.Respons
 		return
 	}
 
-	if checkInput(w, request, request.Content) {
+	if checkInput(w, request) {
+		return
+	}
+
+	maxContentSize := constants.DefaultMaxPayloadSize
+	if gaw.IsInStringArray(action, largeContentActions) {
+		maxContentSize = uint(handlerData.config.Webserver.MaxBodyLength)
+	}
+
+	if checkPayloadSizes(w, maxContentSize, request.Content) {
 		return
 	}
 
@@ -172,6 +223,7 @@ func UpdateSource(db *dbhelper.DBhelper, handlerData handlerData, w http.Respons
 
 	err = nil
 	message := ""
+	var payload interface{}
 	switch action {
 	case actions[0]:
 		{
@@ -209,12 +261,119 @@ func UpdateSource(db *dbhelper.DBhelper, handlerData handlerData, w http.Respons
 			}
 			err = source.Update(db, "private", newVal)
 		}
+	case actions[4]:
+		{
+			//Rotate secret. Content is the grace period of the old secret
+			gracePeriod := handlerData.config.Server.SecretGracePeriod
+			if request.Content != "-" {
+				gracePeriod, err = time.ParseDuration(request.Content)
+				if err != nil || gracePeriod < 0 || gracePeriod > handlerData.config.Server.MaxSecretGracePeriod {
+					sendResponse(w, models.ResponseError, "Invalid grace period", nil, http.StatusUnprocessableEntity)
+					return
+				}
+			}
+
+			err = source.RotateSecret(db, gracePeriod)
+			payload = models.SourceAddResponse{
+				Secret:   source.Secret,
+				SourceID: source.SourceID,
+			}
+		}
+	case actions[5]:
+		{
+			//Set allowed IP ranges separated by ','. '-' allows all IPs
+			var allowlist []string
+			if request.Content != "-" {
+				allowlist = strings.Split(request.Content, ",")
+				for i := range allowlist {
+					allowlist[i] = strings.TrimSpace(allowlist[i])
+				}
+
+				if _, err = models.ParseAllowlist(allowlist); err != nil {
+					sendResponse(w, models.ResponseError, "Invalid IP range: "+err.Error(), nil, http.StatusUnprocessableEntity)
+					return
+				}
+			}
+
+			err = source.UpdateAllowedIPs(db, allowlist)
+		}
+	case actions[6]:
+		{
+			//Set redaction rules as JSON. '-' removes all rules
+			rules := ""
+			if request.Content != "-" {
+				if _, err = models.ParseRedactionRules(request.Content); err != nil {
+					sendResponse(w, models.ResponseError, "Invalid redaction rules: "+err.Error(), nil, http.StatusUnprocessableEntity)
+					return
+				}
+				rules = request.Content
+			}
+
+			err = source.UpdateRedaction(db, rules)
+		}
+	case actions[7]:
+		{
+			//Relay webhooks synchronously to the given subscription. '-' disables it
+			var subscriptionPK uint32
+			if request.Content != "-" {
+				subscription, err := models.GetSubscriptionBySubsID(db, request.Content)
+				if err != nil || subscription.Source != source.PkID {
+					sendResponse(w, models.ResponseError, "Subscription not found", nil, http.StatusNotFound)
+					return
+				}
+				subscriptionPK = subscription.PkID
+			}
+
+			err = source.UpdateSyncSubscription(db, subscriptionPK)
+		}
+	case actions[8]:
+		{
+			//Pause delivery. Webhooks are still accepted and delivered after resuming
+			err = source.SetPaused(db, true)
+			message = "paused"
+		}
+	case actions[9]:
+		{
+			//Resume delivery
+			err = source.SetPaused(db, false)
+			if err == nil {
+				//Hand the webhooks received while the source was paused over to the subscribers
+				handlerData.subscriberCallback.Wake()
+				LogError(models.ResumeOrderedDeliveries(db, handlerData.scheduler, source))
+			}
+			message = "resumed"
+		}
+	case actions[10]:
+		{
+			//Set a JSON schema incoming payloads have to match. '-' removes the schema
+			schema := ""
+			if request.Content != "-" {
+				if _, err = models.ParseJSONSchema(request.Content); err != nil {
+					sendResponse(w, models.ResponseError, "Invalid JSON schema: "+err.Error(), nil, http.StatusUnprocessableEntity)
+					return
+				}
+				schema = request.Content
+			}
+
+			err = source.UpdateJSONSchema(db, schema)
+		}
+	case actions[11]:
+		{
+			//Deduplicate webhooks without a delivery ID by their content. Content is 'true' or 'false'
+			enabled, parseErr := strconv.ParseBool(request.Content)
+			if parseErr != nil {
+				sendResponse(w, models.ResponseError, "Invalid value", nil, http.StatusUnprocessableEntity)
+				return
+			}
+
+			err = source.SetHashDeduplication(db, enabled)
+		}
 	}
 
 	if err != nil {
 		LogError(err)
 		sendServerError(w)
 	} else {
-		sendResponse(w, models.ResponseSuccess, message, nil)
+		sendResponse(w, models.ResponseSuccess, message, payload)
 	}
 }
@@ -2,10 +2,15 @@ package handlers
 
 import (
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	gaw "github.com/JojiiOfficial/GoAw"
 	dbhelper "github.com/JojiiOfficial/GoDBHelper"
 	"github.com/JojiiOfficial/WhShareServer/constants"
 	"github.com/JojiiOfficial/WhShareServer/models"
+	"github.com/gorilla/mux"
 )
 
 //Unsubscribe unsubscribe handler
@@ -81,6 +86,235 @@ func UpdateCallbackURL(db *dbhelper.DBhelper, handler handlerData, w http.Respon
 	}
 }
 
+//UpdateSubscription updates a subscription
+//-> /sub/update/{action}
+func UpdateSubscription(db *dbhelper.DBhelper, handler handlerData, w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	action := vars["action"]
+	actions := []string{
+		"template",
+		"filter",
+		"eventTypes",
+		"rotateSecret",
+		"method",
+		"headers",
+		"auth",
+		"timeout",
+		"maxAttempts",
+		"backoff",
+		"ordered",
+	}
+
+	if !gaw.IsInStringArray(action, actions) {
+		sendError("not available", w, models.WrongInputFormatError, 501)
+		return
+	}
+
+	var request models.SubscriptionUpdateRequest
+	if !parseUserInput(handler.config, w, r, &request) {
+		return
+	}
+
+	if len(request.SubscriptionID) != 32 || len(request.Content) == 0 {
+		sendError("input missing wrong length", w, models.WrongInputFormatError, http.StatusUnprocessableEntity)
+		return
+	}
+
+	subscription, err := models.GetSubscriptionBySubsID(db, request.SubscriptionID)
+	if err != nil {
+		if err.Error() == dbhelper.ErrNoRowsInResultSet {
+			sendResponse(w, models.ResponseError, models.NotFoundError, nil, http.StatusNotFound)
+			return
+		}
+
+		sendServerError(w)
+		return
+	}
+
+	//Update only if it's users subscription or user not logged in and subscriptionID matches
+	if handler.user != nil && subscription.UserID != handler.user.Pkid {
+		sendResponse(w, models.ResponseError, models.ActionNotAllowed, nil, http.StatusForbidden)
+		return
+	}
+
+	var payload interface{}
+	switch action {
+	case actions[0]:
+		{
+			//Set payload template. '-' removes the template
+			if request.Content == "-" {
+				err = subscription.UpdateTemplate(db, "", "")
+				break
+			}
+
+			if err = models.ValidatePayloadTemplate(request.Content, request.ContentType, handler.config.Server.MaxTemplateLength); err != nil {
+				sendResponse(w, models.ResponseError, "Invalid template: "+err.Error(), nil, http.StatusUnprocessableEntity)
+				return
+			}
+
+			err = subscription.UpdateTemplate(db, request.Content, request.ContentType)
+		}
+	case actions[1]:
+		{
+			//Set delivery filter. '-' removes the filter
+			if request.Content == "-" {
+				err = subscription.UpdateFilter(db, "")
+				break
+			}
+
+			if checkPayloadSizes(w, uint(handler.config.Server.MaxFilterLength), request.Content) {
+				return
+			}
+
+			if _, err = models.ParseFilter(request.Content); err != nil {
+				sendResponse(w, models.ResponseError, "Invalid filter: "+err.Error(), nil, http.StatusUnprocessableEntity)
+				return
+			}
+
+			err = subscription.UpdateFilter(db, request.Content)
+		}
+	case actions[2]:
+		{
+			//Set accepted event types separated by ','. '-' accepts all event types
+			var eventTypes []string
+			if request.Content != "-" {
+				if checkPayloadSizes(w, constants.DefaultMaxPayloadSize*10, request.Content) {
+					return
+				}
+
+				for _, eventType := range strings.Split(request.Content, ",") {
+					eventType = models.NormalizeEventType(eventType)
+					if len(eventType) > 0 && !gaw.IsInStringArray(eventType, eventTypes) {
+						eventTypes = append(eventTypes, eventType)
+					}
+				}
+			}
+
+			err = subscription.UpdateEventTypes(db, eventTypes)
+			if err == nil {
+				payload, err = subscriptionResponse(db, subscription)
+			}
+		}
+	case actions[3]:
+		{
+			//Rotate signing secret. Content is the grace period of the old secret
+			gracePeriod := handler.config.Server.SecretGracePeriod
+			if request.Content != "-" {
+				gracePeriod, err = time.ParseDuration(request.Content)
+				if err != nil || gracePeriod < 0 || gracePeriod > handler.config.Server.MaxSecretGracePeriod {
+					sendResponse(w, models.ResponseError, "Invalid grace period", nil, http.StatusUnprocessableEntity)
+					return
+				}
+			}
+
+			err = subscription.RotateSigningSecret(db, gracePeriod)
+			if err == nil {
+				payload, err = subscriptionResponse(db, subscription)
+			}
+		}
+	case actions[4]:
+		{
+			//Set the delivery method. '-' uses the method of the webhook
+			method := ""
+			if request.Content != "-" {
+				method = strings.ToUpper(strings.TrimSpace(request.Content))
+				if !gaw.IsInStringArray(method, models.DeliveryMethods) {
+					sendResponse(w, models.ResponseError, "Invalid method", nil, http.StatusUnprocessableEntity)
+					return
+				}
+			}
+
+			err = subscription.UpdateMethod(db, method)
+			if err == nil {
+				payload, err = subscriptionResponse(db, subscription)
+			}
+		}
+	case actions[5]:
+		{
+			//Set static headers as JSON object. '-' removes all headers
+			var headers map[string]string
+			if request.Content != "-" {
+				if !models.HasEncryptionKey() {
+					sendResponse(w, models.ResponseError, "Headers are not available on this server", nil, http.StatusNotImplemented)
+					return
+				}
+
+				if checkPayloadSizes(w, constants.DefaultMaxPayloadSize*10, request.Content) {
+					return
+				}
+
+				if headers, err = models.ParseStaticHeaders(request.Content); err != nil {
+					sendResponse(w, models.ResponseError, "Invalid headers: "+err.Error(), nil, http.StatusUnprocessableEntity)
+					return
+				}
+			}
+
+			err = subscription.UpdateHeaders(db, headers)
+			if err == nil {
+				payload, err = subscriptionResponse(db, subscription)
+			}
+		}
+	case actions[6]:
+		{
+			//Set basic or bearer auth as JSON. '-' removes the auth
+			var auth *models.DeliveryAuth
+			if request.Content != "-" {
+				if !models.HasEncryptionKey() {
+					sendResponse(w, models.ResponseError, "Auth is not available on this server", nil, http.StatusNotImplemented)
+					return
+				}
+
+				if checkPayloadSizes(w, constants.DefaultMaxPayloadSize*10, request.Content) {
+					return
+				}
+
+				if auth, err = models.ParseDeliveryAuth(request.Content); err != nil {
+					sendResponse(w, models.ResponseError, "Invalid auth: "+err.Error(), nil, http.StatusUnprocessableEntity)
+					return
+				}
+			}
+
+			err = subscription.UpdateAuth(db, auth)
+			if err == nil {
+				payload, err = subscriptionResponse(db, subscription)
+			}
+		}
+	case actions[7], actions[8], actions[9]:
+		{
+			//Set the delivery timeout, max attempts or backoff schedule. '-' uses the default
+			if !updateDeliveryPolicy(db, handler, w, subscription, action, request.Content) {
+				return
+			}
+
+			payload, err = subscriptionResponse(db, subscription)
+		}
+	case actions[10]:
+		{
+			//Deliver webhooks strictly in the order they were received. Content is 'true' or 'false'
+			ordered, parseErr := strconv.ParseBool(request.Content)
+			if parseErr != nil {
+				sendResponse(w, models.ResponseError, "Invalid value", nil, http.StatusUnprocessableEntity)
+				return
+			}
+
+			if ordered != subscription.Ordered {
+				err = subscription.UpdateOrdered(db, ordered)
+			}
+
+			if err == nil {
+				payload, err = subscriptionResponse(db, subscription)
+			}
+		}
+	}
+
+	if err != nil {
+		LogError(err)
+		sendServerError(w)
+	} else {
+		sendResponse(w, models.ResponseSuccess, "", payload)
+	}
+}
+
 //Subscribe subscription handler
 //-> /sub/add
 func Subscribe(db *dbhelper.DBhelper, handler handlerData, w http.ResponseWriter, r *http.Request) {
@@ -177,6 +411,7 @@ func Subscribe(db *dbhelper.DBhelper, handler handlerData, w http.ResponseWriter
 			SubscriptionID: subs.SubscriptionID,
 			Name:           source.Name,
 			Mode:           source.Mode,
+			SigningSecret:  subs.SigningSecret,
 		}
 
 		sendResponse(w, models.ResponseSuccess, "", response)
@@ -185,6 +420,106 @@ func Subscribe(db *dbhelper.DBhelper, handler handlerData, w http.ResponseWriter
 	}
 }
 
+//Update the timeout, max attempts or backoff schedule of the subscription within the limits of its owner.
+//Return false if a response was sent
+func updateDeliveryPolicy(db *dbhelper.DBhelper, handler handlerData, w http.ResponseWriter, subscription *models.Subscription, action, content string) bool {
+	owner := handler.user
+	if owner == nil || owner.Pkid != subscription.UserID {
+		var err error
+		if owner, err = models.GetUserByPK(db, subscription.UserID); err != nil {
+			LogError(err)
+			sendServerError(w)
+			return false
+		}
+	}
+
+	limits := owner.GetDeliveryLimits(handler.config.GetDeliveryLimits())
+	content = strings.TrimSpace(content)
+
+	var err error
+	switch action {
+	case "timeout":
+		var timeout time.Duration
+		if content != "-" {
+			if timeout, err = time.ParseDuration(content); err != nil {
+				sendResponse(w, models.ResponseError, "Invalid duration", nil, http.StatusUnprocessableEntity)
+				return false
+			}
+
+			if err = limits.ValidateTimeout(timeout); err != nil {
+				sendResponse(w, models.ResponseError, err.Error(), nil, http.StatusUnprocessableEntity)
+				return false
+			}
+		}
+
+		err = subscription.UpdateTimeout(db, timeout)
+	case "maxAttempts":
+		var attempts uint64
+		if content != "-" {
+			if attempts, err = strconv.ParseUint(content, 10, 8); err != nil {
+				sendResponse(w, models.ResponseError, "Invalid number", nil, http.StatusUnprocessableEntity)
+				return false
+			}
+
+			if err = limits.ValidateMaxAttempts(int(attempts)); err != nil {
+				sendResponse(w, models.ResponseError, err.Error(), nil, http.StatusUnprocessableEntity)
+				return false
+			}
+		}
+
+		err = subscription.UpdateMaxAttempts(db, uint8(attempts))
+	case "backoff":
+		var backoff []time.Duration
+		if content != "-" {
+			if checkPayloadSizes(w, constants.DefaultMaxPayloadSize*10, content) {
+				return false
+			}
+
+			if backoff, err = limits.ParseBackoff(content); err != nil {
+				sendResponse(w, models.ResponseError, "Invalid backoff: "+err.Error(), nil, http.StatusUnprocessableEntity)
+				return false
+			}
+		}
+
+		err = subscription.UpdateBackoff(db, backoff)
+	}
+
+	if LogError(err) {
+		sendServerError(w)
+		return false
+	}
+
+	return true
+}
+
+//Create the response for a subscription
+func subscriptionResponse(db *dbhelper.DBhelper, subscription *models.Subscription) (*models.SubscriptionResponse, error) {
+	source, err := models.GetSourceByPK(db, subscription.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	headerNames, err := subscription.GetStaticHeaderNames()
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.SubscriptionResponse{
+		SubscriptionID: subscription.SubscriptionID,
+		Name:           source.Name,
+		Mode:           source.Mode,
+		EventTypes:     subscription.GetEventTypes(),
+		SigningSecret:  subscription.SigningSecret,
+		Method:         subscription.Method,
+		Headers:        headerNames,
+		AuthType:       subscription.AuthType,
+		Timeout:        subscription.Timeout,
+		MaxAttempts:    subscription.MaxAttempts,
+		Backoff:        subscription.Backoff,
+		Ordered:        subscription.Ordered,
+	}, nil
+}
+
 //Get list of disallowed IPs
 func genIPBlocklist(ownIP *string, config *models.ConfigStruct) []string {
 	var list []string
@@ -3,21 +3,27 @@ package handlers
 import (
 	"io"
 	"io/ioutil"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
-	gaw "github.com/JojiiOfficial/GoAw"
 	dbhelper "github.com/JojiiOfficial/GoDBHelper"
-	"github.com/JojiiOfficial/WhShareServer/constants"
 	"github.com/JojiiOfficial/WhShareServer/models"
+	"github.com/JojiiOfficial/WhShareServer/modes"
 	"github.com/gorilla/mux"
 )
 
 type webhookResp struct {
 	StatusCode int
 	Message    string
+	RetryAfter time.Duration
+	Relay      *models.DeliveryResponse
+	Errors     []string
 }
 
 //WebhookHandler handler for incoming webhooks
@@ -27,8 +33,18 @@ func WebhookHandler(db *dbhelper.DBhelper, handlerData handlerData, w http.Respo
 	sourceID := vars["sourceID"]
 	secret := vars["secret"]
 
-	if len(sourceID) == 0 || len(secret) == 0 {
-		log.Info("source or secret is not given in webhook!")
+	if len(sourceID) == 0 {
+		log.Info("source is not given in webhook!")
+		return
+	}
+
+	senderIP := getSenderIP(r, handlerData.config.Server.TrustedProxies)
+	rateLimit := handlerData.config.Server.RateLimit
+
+	//Limit webhooks per client IP
+	if allowed, wait := handlerData.rateLimiter.allow("ip:"+rateLimitKey(senderIP), rateLimit.IPRate, rateLimit.IPBurst); !allowed {
+		log.Warnf("Rate limit exceeded for IP '%s'\n", senderIP)
+		sendRateLimitExceeded(w, wait)
 		return
 	}
 
@@ -39,108 +55,385 @@ func WebhookHandler(db *dbhelper.DBhelper, handlerData handlerData, w http.Respo
 		return
 	}
 
-	if source.Secret == secret {
-		c := make(chan webhookResp, 1)
-		log.Infof("New webhook: %s\n", source.Name)
-
-		go (func(req *http.Request) {
-			userChan := make(chan *models.User, 1)
-
-			//Get source user
-			go (func() {
-				us, err := models.GetUserByPK(db, source.CreatorID)
-				if err != nil {
-					LogError(err)
-					userChan <- nil
-				} else {
-					userChan <- us
-				}
-			})()
-
-			//Don't forward the webhook if it contains a header-value pair which is on the blacklist
-			if isHeaderBlocklistetd(req.Header, &handlerData.config.Server.WebhookBlacklist.HeaderValues) {
-				log.Warnf("Blocked webhook '%s' because of header-blacklist\n", source.SourceID)
-				c <- webhookResp{StatusCode: http.StatusOK, Message: "Content won't forwarded"}
-				return
-			}
+	//Only accept webhooks from allowed IP ranges
+	if !isSenderAllowed(handlerData.config, source, senderIP) {
+		log.Warnf("Rejected webhook for source '%s': IP not allowed\n", source.SourceID)
+		http.Error(w, "IP not allowed", http.StatusForbidden)
+		return
+	}
 
-			//Await getting user
-			user := <-userChan
+	//Read payload from webhook. One byte more than allowed shows if it's too long
+	maxLength := handlerData.config.Webserver.MaxPayloadBodyLength
+	payload, err := ioutil.ReadAll(io.LimitReader(r.Body, maxLength+1))
+	if err != nil {
+		LogError(err)
+		http.Error(w, "error reading payload", http.StatusInternalServerError)
+		return
+	}
+	r.Body.Close()
 
-			//Validate user
-			if user == nil {
-				c <- webhookResp{
-					StatusCode: http.StatusBadRequest,
-					Message:    "User not found",
-				}
-				return
-			}
+	//A cut payload would fail the verification. Reject it before
+	if int64(len(payload)) > maxLength {
+		log.Warnf("Rejected webhook for source '%s': payload too long\n", source.SourceID)
+		http.Error(w, "payload too long", http.StatusRequestEntityTooLarge)
+		return
+	}
 
-			//return error if user not allowed to send hooks
-			if !user.CanShareWebhooks() {
-				c <- webhookResp{StatusCode: http.StatusMethodNotAllowed, Message: "not allowed to send webhooks"}
-				return
-			}
+	//Verify the origin of the webhook
+	if err = verifyWebhook(source, secret, r.Header, payload); err != nil {
+		log.Warnf("Rejected webhook for source '%s': %s\n", source.SourceID, err.Error())
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	mode, hasMode := modes.Get(source.Mode)
 
-			//Read payload from webhook
-			payload, err := ioutil.ReadAll(io.LimitReader(req.Body, handlerData.config.Webserver.MaxPayloadBodyLength))
+	//Never store or forward the proof of the provider. Subscribers could use it to forge webhooks
+	if hasMode {
+		for _, secretHeader := range mode.SecretHeaders() {
+			r.Header.Del(secretHeader)
+		}
+	}
+
+	//Answer verification requests of the provider directly
+	if hasMode {
+		if handshake := mode.Handshake(r.Header, payload); handshake != nil {
+			log.Infof("Answered handshake for source '%s'\n", source.SourceID)
+			sendHandshake(w, handshake)
+			return
+		}
+	}
+
+	c := make(chan webhookResp, 1)
+	log.Infof("New webhook: %s\n", source.Name)
+
+	go (func(req *http.Request) {
+		userChan := make(chan *models.User, 1)
+
+		//Get source user
+		go (func() {
+			us, err := models.GetUserByPK(db, source.CreatorID)
 			if err != nil {
 				LogError(err)
-				c <- webhookResp{StatusCode: http.StatusInternalServerError, Message: "error reading payload"}
-				return
+				userChan <- nil
+			} else {
+				userChan <- us
 			}
-			req.Body.Close()
+		})()
 
-			headers := headerToString(req.Header)
+		//Don't forward the webhook if it contains a header-value pair which is on the blacklist
+		if isHeaderBlocklistetd(req.Header, &handlerData.config.Server.WebhookBlacklist.HeaderValues) {
+			log.Warnf("Blocked webhook '%s' because of header-blacklist\n", source.SourceID)
+			c <- webhookResp{StatusCode: http.StatusOK, Message: "Content won't forwarded"}
+			return
+		}
 
-			//Calculate traffic of request
-			reqTraffic := uint32(len(payload)) + uint32(len(headers))
+		//Await getting user
+		user := <-userChan
 
-			//Check if user limit exceeded
-			if (user.Role.MaxTraffic != -1 && uint32(user.Role.MaxTraffic*1024) <= (user.Traffic+reqTraffic)) ||
-				(user.Role.MaxHookCalls != -1 && user.Role.MaxHookCalls < int(user.HookCalls+1)) {
-				c <- webhookResp{StatusCode: http.StatusForbidden, Message: "traffic/hookCall limit exceeded"}
-				return
+		//Validate user
+		if user == nil {
+			c <- webhookResp{
+				StatusCode: http.StatusBadRequest,
+				Message:    "User not found",
 			}
+			return
+		}
 
-			//Delete in config specified json objects
-			payload, err = gaw.JSONRemoveItems(payload, handlerData.config.Server.WebhookBlacklist.JSONObjects[constants.ModeToString[source.Mode]], false)
-			if err != nil {
-				LogError(err, log.Fields{"msg": "Error filtering JSON!"})
-				c <- webhookResp{StatusCode: http.StatusInternalServerError, Message: "server error"}
+		//return error if user not allowed to send hooks
+		if !user.CanShareWebhooks() {
+			c <- webhookResp{StatusCode: http.StatusMethodNotAllowed, Message: "not allowed to send webhooks"}
+			return
+		}
+
+		//Limit webhooks per source. Rejected webhooks don't count as hookCall
+		sourceRate, sourceBurst := user.GetHookRateLimit(rateLimit.SourceRate, rateLimit.SourceBurst)
+		if allowed, wait := handlerData.rateLimiter.allow("source:"+source.SourceID, sourceRate, sourceBurst); !allowed {
+			log.Warnf("Rate limit exceeded for source '%s'\n", source.SourceID)
+			c <- webhookResp{StatusCode: http.StatusTooManyRequests, Message: "rate limit exceeded", RetryAfter: wait}
+			return
+		}
+
+		//Reject payloads not matching the JSON schema of the source
+		if errs, err := validatePayload(source, payload); err != nil {
+			LogError(err, log.Fields{"msg": "Error validating payload!", "source": source.SourceID})
+			c <- webhookResp{StatusCode: http.StatusInternalServerError, Message: "server error"}
+			return
+		} else if len(errs) > 0 {
+			log.Warnf("Rejected webhook for source '%s': payload doesn't match the JSON schema\n", source.SourceID)
+			LogError(source.AddValidationFailure(db))
+			c <- webhookResp{StatusCode: http.StatusUnprocessableEntity, Message: "invalid payload", Errors: errs}
+			return
+		}
+
+		//Calculate traffic of request
+		reqTraffic := uint32(len(payload)) + getHeaderSize(req.Header)
+
+		//Check if user limit exceeded
+		if (user.Role.MaxTraffic != -1 && uint32(user.Role.MaxTraffic*1024) <= (user.Traffic+reqTraffic)) ||
+			(user.Role.MaxHookCalls != -1 && user.Role.MaxHookCalls < int(user.HookCalls+1)) {
+			c <- webhookResp{StatusCode: http.StatusForbidden, Message: "traffic/hookCall limit exceeded"}
+			return
+		}
+
+		var params []string
+		if p, has := vars["params"]; has {
+			params = strings.Split(p, "&")
+		}
+
+		//Delete in config specified objects and append params
+		payload, err := processPayload(req.Header.Get("Content-Type"), payload, getJSONFilters(handlerData.config, source.Mode), params)
+		if err != nil {
+			if err == ErrInvalidJSON {
+				c <- webhookResp{StatusCode: http.StatusBadRequest, Message: err.Error()}
 				return
 			}
 
+			LogError(err, log.Fields{"msg": "Error processing payload!"})
+			c <- webhookResp{StatusCode: http.StatusInternalServerError, Message: "server error"}
+			return
+		}
+
+		eventType := getEventType(source.Mode, req.Header, payload)
+
+		//Remove sensitive data before storing the webhook
+		header := req.Header.Clone()
+		payload, err = source.Redact(header, payload)
+		if err != nil {
+			LogError(err, log.Fields{"msg": "Error redacting webhook!", "source": source.SourceID})
+			c <- webhookResp{StatusCode: http.StatusInternalServerError, Message: "server error"}
+			return
+		}
+
+		webhook := &models.Webhook{
+			SourceID:   source.PkID,
+			Headers:    models.EncodeHeader(header),
+			Method:     req.Method,
+			Query:      req.URL.RawQuery,
+			Payload:    string(payload),
+			EventType:  eventType,
+			DeliveryID: getDeliveryID(source, req, payload),
+		}
+
+		//Synchronous sources deliver to their primary subscription directly.
+		//Paused sources don't deliver webhooks until they get resumed
+		var syncSubscription *models.Subscription
+		if source.IsSync() && !source.IsPaused {
+			syncSubscription, err = source.GetSyncSubscription(db)
+			if err != nil || syncSubscription.Source != source.PkID {
+				log.Warnf("Primary subscription of source '%s' not found. Delivering asynchronously\n", source.SourceID)
+				syncSubscription = nil
+			} else if syncSubscription.Ordered {
+				//Relaying could overtake webhooks which are still queued or held back
+				log.Infof("Primary subscription of source '%s' is ordered. Delivering asynchronously\n", source.SourceID)
+				syncSubscription = nil
+			} else if !webhook.IsAcceptedBy(*syncSubscription) {
+				//The filters of the subscription decide in the outbox
+				log.Debugf("Primary subscription of source '%s' doesn't accept the webhook. Delivering asynchronously\n", source.SourceID)
+				syncSubscription = nil
+			}
+		}
+
+		var excludedSubscription uint32
+		if syncSubscription != nil {
+			excludedSubscription = syncSubscription.PkID
+		}
+
+		//Store the webhook before acknowledging it
+		//Don't store a webhook twice if the provider resends it
+		err = webhook.InsertWithOutbox(db, excludedSubscription, handlerData.config.Server.DeduplicationWindow)
+		if err == models.ErrDuplicateWebhook {
+			log.Infof("Ignoring duplicate webhook '%s' for source '%s'\n", webhook.DeliveryID, source.SourceID)
+			source.AddDuplicate(db)
+			c <- webhookResp{StatusCode: http.StatusOK, Message: "Duplicate"}
+			return
+		}
+
+		if err != nil {
+			LogError(err, log.Fields{"msg": "Error storing webhook!"})
+			c <- webhookResp{StatusCode: http.StatusInternalServerError, Message: "server error"}
+			return
+		}
+
+		//Update traffic and hookCallCount if not both unlimited
+		if !user.HasUnlimitedHookCalls() {
+			user.AddHookCall(db, reqTraffic)
+		}
+
+		if syncSubscription != nil {
+			c <- relayWebhook(db, handlerData, webhook, source, syncSubscription)
+		} else {
 			//Send success
 			c <- webhookResp{
 				StatusCode: http.StatusOK,
 				Message:    "Success",
 			}
+		}
 
-			//Update traffic and hookCallCount if not both unlimited
-			if !user.HasUnlimitedHookCalls() {
-				user.AddHookCall(db, reqTraffic)
-			}
+		//Hand the webhook over to the outbox
+		handlerData.subscriberCallback.OnWebhookReceive(webhook, source)
+	})(r)
 
-			//append params
-			if p, has := vars["params"]; has {
-				payload, err = appendJSONKeys(payload, strings.Split(p, "&")...)
-			}
+	res := <-c
+	if res.StatusCode == http.StatusTooManyRequests {
+		sendRateLimitExceeded(w, res.RetryAfter)
+		return
+	}
 
-			webhook := &models.Webhook{
-				SourceID: source.PkID,
-				Headers:  headers,
-				Payload:  string(payload),
-			}
-			webhook.Insert(db)
+	if res.Relay != nil {
+		sendRelayedResponse(w, res.Relay)
+		return
+	}
 
-			handlerData.subscriberCallback.OnWebhookReceive(webhook, source)
-		})(r)
+	if len(res.Errors) > 0 {
+		sendResponse(w, models.ResponseError, res.Message, models.SchemaValidationResponse{Errors: res.Errors}, res.StatusCode)
+		return
+	}
+
+	http.Error(w, res.Message, res.StatusCode)
+}
+
+//Return the IP of the client sending the webhook. X-Forwarded-For is only
+//used if the request comes from a trusted proxy. The rightmost untrusted hop is the client
+func getSenderIP(r *http.Request, trustedProxies []string) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	ip = normalizeIP(ip)
+
+	trusted, err := models.ParseAllowlist(trustedProxies)
+	if LogError(err) || len(trusted) == 0 {
+		return ip
+	}
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+
+	for i := len(hops); isTrustedProxy(ip, trusted) && i > 0; i-- {
+		hop := normalizeIP(hops[i-1])
+		//Stop at invalid entries and use the last valid hop
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		ip = hop
+	}
+
+	return ip
+}
+
+//Return the key of the rate limit bucket of ip. IPv6 clients usually own
+//a whole /64 network, so they share one bucket
+func rateLimitKey(ip string) string {
+	parsedIP := net.ParseIP(ip)
+	if parsedIP == nil || parsedIP.To4() != nil {
+		return ip
+	}
+
+	return parsedIP.Mask(net.CIDRMask(64, 128)).String() + "/64"
+}
+
+//Return the IP in its canonical form
+func normalizeIP(ip string) string {
+	ip = strings.Trim(strings.TrimSpace(ip), "[]")
+	if parsed := net.ParseIP(ip); parsed != nil {
+		return parsed.String()
+	}
+	return ip
+}
+
+//Return true if ip is in one of the trusted proxy ranges
+func isTrustedProxy(ip string, trusted []*net.IPNet) bool {
+	parsedIP := net.ParseIP(ip)
+	if parsedIP == nil {
+		return false
+	}
+
+	for _, ipNet := range trusted {
+		if ipNet.Contains(parsedIP) {
+			return true
+		}
+	}
 
-		res := <-c
-		http.Error(w, res.Message, res.StatusCode)
+	return false
+}
+
+//Send 429 with the time the client has to wait
+func sendRateLimitExceeded(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+}
+
+//Return true if the sender of the webhook is in the allowlists of the source and its mode
+func isSenderAllowed(config *models.ConfigStruct, source *models.Source, ip string) bool {
+	allowlists := [][]string{
+		config.Server.ModeIPAllowlists[modes.NameOf(source.Mode)],
+		source.GetAllowedIPs(),
+	}
+
+	for _, allowlist := range allowlists {
+		allowed, err := models.IsIPInAllowlist(ip, allowlist)
+		if LogError(err) || !allowed {
+			return false
+		}
+	}
+
+	return true
+}
+
+//validatePayload returns the violations of the JSON schema of the source.
+//Sources without a schema accept all payloads
+func validatePayload(source *models.Source, payload []byte) ([]string, error) {
+	schema, err := source.GetJSONSchema()
+	if err != nil || schema == nil {
+		return nil, err
+	}
+
+	errs, err := schema.Validate(payload)
+	if err == models.ErrPayloadNotJSON {
+		return []string{err.Error()}, nil
+	}
 
-	} else {
-		log.Warn("invalid secret for source", sourceID)
+	return errs, err
+}
+
+//sendHandshake answers a verification request of a provider
+func sendHandshake(w http.ResponseWriter, handshake *modes.HandshakeResponse) {
+	if len(handshake.ContentType) > 0 {
+		w.Header().Set("Content-Type", handshake.ContentType)
+	}
+
+	w.WriteHeader(handshake.StatusCode)
+	w.Write(handshake.Body)
+}
+
+//relayWebhook delivers the webhook to the primary subscription and returns its response
+func relayWebhook(db *dbhelper.DBhelper, handlerData handlerData, webhook *models.Webhook, source *models.Source, subscription *models.Subscription) webhookResp {
+	response, err := subscription.NotifyWithTimeout(db, webhook, source, handlerData.notifyCallback, handlerData.config.Server.SyncRelayTimeout)
+	if err != nil {
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			return webhookResp{StatusCode: http.StatusGatewayTimeout, Message: "subscriber timed out"}
+		}
+		return webhookResp{StatusCode: http.StatusBadGateway, Message: "subscriber not reachable"}
 	}
+
+	return webhookResp{StatusCode: response.StatusCode, Relay: response}
+}
+
+//sendRelayedResponse sends the response of the primary subscription back to the sender
+func sendRelayedResponse(w http.ResponseWriter, response *models.DeliveryResponse) {
+	header := response.Header.Clone()
+	models.RemoveHopByHopHeaders(header)
+	header.Del("Content-Length")
+
+	for key, values := range header {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+
+	w.WriteHeader(response.StatusCode)
+	w.Write(response.Body)
 }
@@ -258,14 +258,6 @@ func isHeaderBlocklistetd(headers http.Header, blocklist *map[string][]string) b
 	return false
 }
 
-func headerToString(headers http.Header) string {
-	var sheaders string
-	for k, v := range headers {
-		sheaders += k + "=" + strings.Join(v, ";") + "\r\n"
-	}
-	return sheaders
-}
-
 //Returns the size in bytes of the header
 func getHeaderSize(headers http.Header) uint32 {
 	var size uint32
@@ -278,7 +270,7 @@ func getHeaderSize(headers http.Header) uint32 {
 	return size
 }
 
-//Appends keys value pairs separated by = to the given json
+//Appends keys value pairs separated by = to the given json object
 func appendJSONKeys(jsonContent []byte, kvpairs ...string) ([]byte, error) {
 	var parsedJSON interface{}
 	err := json.Unmarshal([]byte(jsonContent), &parsedJSON)
@@ -286,25 +278,18 @@ func appendJSONKeys(jsonContent []byte, kvpairs ...string) ([]byte, error) {
 		return []byte{}, err
 	}
 
-	//typecast to map[string]interface{}
-	mp := (parsedJSON.(map[string]interface{}))
-
-	//Loop key value pairs and append them
-	for _, kvpair := range kvpairs {
-		if !strings.Contains(kvpair, "=") {
-			continue
-		}
-
-		//get key
-		splitted := strings.Split(kvpair, "=")
-		key := splitted[0]
+	//Keys can only be appended to objects
+	mp, ok := parsedJSON.(map[string]interface{})
+	if !ok {
+		return jsonContent, nil
+	}
 
-		//Append value
-		value := strings.Join(splitted[1:], "=")
+	//Append key value pairs
+	for key, value := range parseParams(kvpairs) {
 		mp[key] = value
 	}
 
 	//Parse the object back to json
-	m, err := json.Marshal(parsedJSON)
+	m, err := json.Marshal(mp)
 	return m, err
 }
@@ -121,6 +121,13 @@ func main() {
 			return
 		}
 
+		if err := config.RegisterCustomModes(); err != nil {
+			log.Fatalln(err.Error())
+			return
+		}
+
+		config.InitEncryption()
+
 		var err error
 		db, err = storage.ConnectDB(config, isDebug, *appNoColor)
 		if err != nil {
@@ -2,12 +2,26 @@ package models
 
 //SubscriberNotifyCallback callback for user notifications
 type SubscriberNotifyCallback interface {
-	OnWebhookReceive(*Webhook, *Source)
+	OnWebhookReceive(*Webhook, *Source) error
 }
 
-//NotifyCallback callback for Notify
+//Outbox hands stored webhooks over to the subscribers. Wake makes it handle
+//pending webhooks immediately, eg. after a source was resumed
+type Outbox interface {
+	SubscriberNotifyCallback
+	Wake()
+}
+
+//NotifyCallback callback for Notify. OnError is called for every failed delivery,
+//also if the request couldn't be created
 type NotifyCallback interface {
-	OnSuccess(Subscription)
+	OnSuccess(Subscription, Webhook)
 	OnError(Subscription, Source, Webhook)
 	OnUnsubscribe(Subscription)
 }
+
+//DeliveryScheduler queues deliveries and notifies the subscribers
+type DeliveryScheduler interface {
+	Schedule(...Delivery) error
+	Stats() DeliveryStats
+}
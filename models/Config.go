@@ -8,6 +8,7 @@ import (
 
 	gaw "github.com/JojiiOfficial/GoAw"
 	"github.com/JojiiOfficial/WhShareServer/constants"
+	"github.com/JojiiOfficial/WhShareServer/modes"
 	"github.com/JojiiOfficial/configService"
 	log "github.com/sirupsen/logrus"
 )
@@ -30,10 +31,21 @@ type configWhBlacklist struct {
 	JSONObjects  map[string][]string
 }
 
+type configRateLimit struct {
+	SourceRate  int `default:"300"`
+	SourceBurst int `default:"30"`
+	IPRate      int `default:"600"`
+	IPBurst     int `default:"60"`
+	MaxBuckets  int `default:"10000"`
+}
+
 type configRetries struct {
 	RetryTimes         map[uint8]time.Duration
 	RetryInterval      time.Duration `required:"true"`
 	InvalidUserRetries uint8         `required:"true" default:"2"`
+	MaxAttempts        int           `default:"10"`
+	MinBackoff         time.Duration `default:"10s"`
+	MaxBackoff         time.Duration `default:"24h"`
 }
 
 type configServer struct {
@@ -43,8 +55,27 @@ type configServer struct {
 	BogonAsCallback      bool `default:"false"`
 	ServerHostAsCallback bool `default:"false"`
 	BlocklistIPs         []string
+	TrustedProxies       []string
 	WorkerCount          int `default:"8"`
+	DeliveryQueueSize    int `default:"1000"`
+	MaxInFlightPerSubs   int `default:"2"`
+	MaxInFlightPerSource int `default:"4"`
 	CleanSessionsAfter   time.Duration
+	SecretGracePeriod    time.Duration `default:"24h"`
+	MaxSecretGracePeriod time.Duration `default:"168h"`
+	MaxTemplateLength    int           `default:"5000"`
+	MaxFilterLength      int           `default:"1000"`
+	DeduplicationWindow  time.Duration `default:"1h"`
+	ModeIPAllowlists     map[string][]string
+	CustomModes          []modes.Definition
+	RateLimit            configRateLimit
+	OutboxInterval       time.Duration `default:"10s"`
+	SyncRelayTimeout     time.Duration `default:"5s"`
+	MaxReplayCount       int           `default:"100"`
+	MaxReplayAge         time.Duration `default:"48h"`
+	KeepAttemptsFor      time.Duration `default:"168h"`
+	MaxDeliveryTimeout   time.Duration `default:"60s"`
+	EncryptionKey        string
 	Retries              configRetries
 }
 
@@ -98,9 +129,36 @@ func InitConfig(confFile string, createMode bool) (*ConfigStruct, bool) {
 		config = ConfigStruct{
 			Server: configServer{
 				AllowRegistration:    false,
+				WorkerCount:          8,
+				DeliveryQueueSize:    1000,
+				MaxInFlightPerSubs:   2,
+				MaxInFlightPerSource: 4,
 				BogonAsCallback:      false,
 				ServerHostAsCallback: false,
 				CleanSessionsAfter:   386 * time.Hour,
+				SecretGracePeriod:    24 * time.Hour,
+				MaxSecretGracePeriod: 168 * time.Hour,
+				MaxTemplateLength:    5000,
+				MaxFilterLength:      1000,
+				DeduplicationWindow:  1 * time.Hour,
+				TrustedProxies:       []string{},
+				ModeIPAllowlists: map[string][]string{
+					"github": []string{},
+					"gitlab": []string{},
+				},
+				OutboxInterval:     10 * time.Second,
+				SyncRelayTimeout:   5 * time.Second,
+				MaxReplayCount:     100,
+				MaxReplayAge:       48 * time.Hour,
+				KeepAttemptsFor:    168 * time.Hour,
+				MaxDeliveryTimeout: 60 * time.Second,
+				RateLimit: configRateLimit{
+					SourceRate:  300,
+					SourceBurst: 30,
+					IPRate:      600,
+					IPBurst:     60,
+					MaxBuckets:  10000,
+				},
 				Retries: configRetries{
 					RetryTimes: map[uint8]time.Duration{
 						0: 1 * time.Minute,
@@ -112,6 +170,9 @@ func InitConfig(confFile string, createMode bool) (*ConfigStruct, bool) {
 					},
 					RetryInterval:      10 * time.Second,
 					InvalidUserRetries: 2,
+					MaxAttempts:        10,
+					MinBackoff:         10 * time.Second,
+					MaxBackoff:         24 * time.Hour,
 				},
 				Database: configDBstruct{
 					Host:         "localhost",
@@ -223,6 +284,56 @@ func (config *ConfigStruct) Check() bool {
 		}
 	}
 
+	for _, definition := range config.Server.CustomModes {
+		if _, err := modes.NewDeclarativeMode(definition); err != nil {
+			log.Errorf("Invalid custom mode: %s\n", err.Error())
+			return false
+		}
+	}
+
+	if config.Server.WorkerCount < 1 || config.Server.DeliveryQueueSize < 1 {
+		log.Error("WorkerCount and DeliveryQueueSize have to be at least 1")
+		return false
+	}
+
+	if config.Server.MaxInFlightPerSubs < 0 || config.Server.MaxInFlightPerSource < 0 {
+		log.Error("MaxInFlightPerSubs and MaxInFlightPerSource can't be negative")
+		return false
+	}
+
+	if config.Server.MaxDeliveryTimeout < time.Second || config.Server.Retries.MaxAttempts < 1 || config.Server.Retries.MaxAttempts > 255 {
+		log.Error("MaxDeliveryTimeout has to be at least 1s and MaxAttempts between 1 and 255")
+		return false
+	}
+
+	if config.Server.Retries.MaxBackoff < config.Server.Retries.MinBackoff {
+		log.Error("MaxBackoff has to be at least MinBackoff")
+		return false
+	}
+
+	if len(config.Server.EncryptionKey) > 0 && len(config.Server.EncryptionKey) < 32 {
+		log.Error("EncryptionKey has to be at least 32 characters long")
+		return false
+	}
+
+	//The webserver closes connections after 10 seconds
+	if config.Server.SyncRelayTimeout <= 0 || config.Server.SyncRelayTimeout >= 10*time.Second {
+		log.Error("SyncRelayTimeout has to be between 0 and 10 seconds")
+		return false
+	}
+
+	if _, err := ParseAllowlist(config.Server.TrustedProxies); err != nil {
+		log.Errorf("Invalid trusted proxies: %s\n", err.Error())
+		return false
+	}
+
+	for mode, allowlist := range config.Server.ModeIPAllowlists {
+		if _, err := ParseAllowlist(allowlist); err != nil {
+			log.Errorf("Invalid IP allowlist for mode '%s': %s\n", mode, err.Error())
+			return false
+		}
+	}
+
 	if config.Server.Database.DatabasePort < 1 || config.Server.Database.DatabasePort > 65535 {
 		log.Errorf("Invalid port for database %d\n", config.Server.Database.DatabasePort)
 		return false
@@ -230,3 +341,13 @@ func (config *ConfigStruct) Check() bool {
 
 	return true
 }
+
+//InitEncryption sets the key used to encrypt credentials
+func (config *ConfigStruct) InitEncryption() {
+	SetEncryptionKey(config.Server.EncryptionKey)
+}
+
+//RegisterCustomModes registers the modes declared in the config
+func (config *ConfigStruct) RegisterCustomModes() error {
+	return modes.RegisterDefinitions(config.Server.CustomModes)
+}
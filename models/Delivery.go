@@ -0,0 +1,26 @@
+package models
+
+import "errors"
+
+//Delivery a webhook which has to be delivered to a subscription.
+//Deliveries to ordered subscriptions without a webhook deliver the next webhook the subscription didn't receive yet
+type Delivery struct {
+	Webhook      *Webhook
+	Source       *Source
+	Subscription *Subscription
+
+	//Ordered deliveries don't start while another delivery of the subscription is in flight
+	Ordered bool
+}
+
+//DeliveryStats current state of the delivery queue
+type DeliveryStats struct {
+	Workers  int `json:"workers"`
+	Capacity int `json:"capacity"`
+	Queued   int `json:"queued"`
+	InFlight int `json:"inFlight"`
+	Sources  int `json:"sources"`
+}
+
+//ErrQueueFull error if the delivery queue has no space left
+var ErrQueueFull = errors.New("delivery queue full")
@@ -0,0 +1,109 @@
+package models
+
+import (
+	"net/url"
+	"strings"
+	"time"
+
+	dbhelper "github.com/JojiiOfficial/GoDBHelper"
+)
+
+//DeliveryAttempt an attempt to deliver a webhook to a subscription
+type DeliveryAttempt struct {
+	PkID           uint32    `db:"pk_id" orm:"pk,ai" json:"id"`
+	WebhookPK      uint32    `db:"webhookID" json:"webhookID"`
+	SubscriptionPK uint32    `db:"subscriptionID" json:"-"`
+	SubscriptionID string    `db:"subsID" orm:"-" json:"subscriptionID"`
+	Attempt        uint16    `db:"attempt" json:"attempt"`
+	IsReplay       bool      `db:"isReplay" json:"isReplay,omitempty"`
+	StatusCode     int       `db:"statusCode" json:"statusCode,omitempty"`
+	Latency        uint32    `db:"latency" json:"latency"`
+	Error          string    `db:"error" json:"error,omitempty"`
+	ResponseBody   string    `db:"responseBody" json:"responseBody,omitempty"`
+	Created        time.Time `db:"created" json:"created"`
+}
+
+//TableDeliveryAttempts table containing the delivery attempts
+const TableDeliveryAttempts = "DeliveryAttempts"
+
+//Max length of the stored response body
+const maxAttemptBodyLength = 1024
+
+//DeliveryAttemptFilter filters delivery attempts
+type DeliveryAttemptFilter struct {
+	SourcePK       uint32
+	SubscriptionPK uint32
+	WebhookPK      uint32
+	Cursor         uint32
+	Limit          int
+}
+
+//recordDeliveryAttempt stores an attempt to deliver the webhook. The attempt number is counted per webhook and subscription
+func recordDeliveryAttempt(db *dbhelper.DBhelper, webhook *Webhook, subscription *Subscription, response *DeliveryResponse, latency time.Duration, deliveryErr error) {
+	var statusCode int
+	var body, errText string
+
+	if response != nil {
+		statusCode = response.StatusCode
+		body = strings.ToValidUTF8(string(response.Body), "")
+		if len(body) > maxAttemptBodyLength {
+			body = strings.ToValidUTF8(body[:maxAttemptBodyLength], "")
+		}
+	}
+
+	if deliveryErr != nil {
+		//Don't store the callback URL contained in url errors
+		if urlErr, ok := deliveryErr.(*url.Error); ok {
+			deliveryErr = urlErr.Err
+		}
+		errText = deliveryErr.Error()
+	}
+
+	_, err := db.Execf("INSERT INTO %s (webhookID, subscriptionID, attempt, isReplay, statusCode, latency, error, responseBody) SELECT ?, ?, COUNT(*)+1, ?, ?, ?, ?, ? FROM %s WHERE webhookID=? AND subscriptionID=?",
+		[]string{TableDeliveryAttempts, TableDeliveryAttempts},
+		webhook.PkID, subscription.PkID, webhook.IsReplay, statusCode, latency.Milliseconds(), errText, body, webhook.PkID, subscription.PkID)
+	LogError(err)
+}
+
+//GetDeliveryAttempts returns the delivery attempts matching the filter from new to old
+func GetDeliveryAttempts(db *dbhelper.DBhelper, filter DeliveryAttemptFilter) ([]DeliveryAttempt, error) {
+	//Join the public ID of the subscription
+	query := "SELECT %s.*, %s.subscriptionID AS subsID FROM %s JOIN %s ON %s.pk_id = %s.subscriptionID WHERE 1=1"
+	tables := []string{TableDeliveryAttempts, TableSubscriptions, TableDeliveryAttempts, TableSubscriptions, TableSubscriptions, TableDeliveryAttempts}
+	var args []interface{}
+
+	if filter.SourcePK > 0 {
+		query += " AND %s.source = ?"
+		tables = append(tables, TableSubscriptions)
+		args = append(args, filter.SourcePK)
+	}
+	if filter.SubscriptionPK > 0 {
+		query += " AND %s.subscriptionID = ?"
+		tables = append(tables, TableDeliveryAttempts)
+		args = append(args, filter.SubscriptionPK)
+	}
+	if filter.WebhookPK > 0 {
+		query += " AND %s.webhookID = ?"
+		tables = append(tables, TableDeliveryAttempts)
+		args = append(args, filter.WebhookPK)
+	}
+	if filter.Cursor > 0 {
+		query += " AND %s.pk_id < ?"
+		tables = append(tables, TableDeliveryAttempts)
+		args = append(args, filter.Cursor)
+	}
+
+	query += " ORDER BY %s.pk_id DESC LIMIT ?"
+	tables = append(tables, TableDeliveryAttempts)
+	args = append(args, filter.Limit)
+
+	var attempts []DeliveryAttempt
+	err := db.QueryRowsf(&attempts, query, tables, args...)
+	return attempts, err
+}
+
+//DeleteOldDeliveryAttempts deletes delivery attempts older than the given time
+func DeleteOldDeliveryAttempts(db *dbhelper.DBhelper, olderThan time.Time) error {
+	_, err := db.Execf("DELETE FROM %s WHERE created < FROM_UNIXTIME(?)", []string{TableDeliveryAttempts}, olderThan.Unix())
+	return err
+}
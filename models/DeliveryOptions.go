@@ -0,0 +1,272 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	dbhelper "github.com/JojiiOfficial/GoDBHelper"
+	"github.com/JojiiOfficial/WhShareServer/constants"
+)
+
+//Auth types of deliveries
+const (
+	AuthBasic  = "basic"
+	AuthBearer = "bearer"
+)
+
+//DeliveryMethods methods a subscription can use to deliver webhooks
+var DeliveryMethods = []string{
+	http.MethodGet,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+}
+
+//Headers which can't be set as static headers
+var reservedHeaders = []string{
+	"Authorization",
+	"Content-Length",
+	"Host",
+}
+
+//DeliveryAuth credentials sent with each delivery
+type DeliveryAuth struct {
+	Type     string `json:"type"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Token    string `json:"token,omitempty"`
+}
+
+//ParseDeliveryAuth parses and validates auth settings in JSON format
+func ParseDeliveryAuth(content string) (*DeliveryAuth, error) {
+	var auth DeliveryAuth
+	if err := json.Unmarshal([]byte(content), &auth); err != nil {
+		return nil, err
+	}
+
+	auth.Type = strings.ToLower(strings.TrimSpace(auth.Type))
+	switch auth.Type {
+	case AuthBasic:
+		if len(auth.Username) == 0 {
+			return nil, errors.New("username missing")
+		}
+		auth.Token = ""
+	case AuthBearer:
+		if len(auth.Token) == 0 {
+			return nil, errors.New("token missing")
+		}
+		auth.Username, auth.Password = "", ""
+	default:
+		return nil, fmt.Errorf("unknown auth type '%s'", auth.Type)
+	}
+
+	return &auth, nil
+}
+
+//apply sets the Authorization header
+func (auth DeliveryAuth) apply(req *http.Request) {
+	switch auth.Type {
+	case AuthBasic:
+		req.SetBasicAuth(auth.Username, auth.Password)
+	case AuthBearer:
+		req.Header.Set("Authorization", "Bearer "+auth.Token)
+	}
+}
+
+//ParseStaticHeaders parses and validates static headers in JSON format like {"X-Name": "value"}
+func ParseStaticHeaders(content string) (map[string]string, error) {
+	var headers map[string]string
+	if err := json.Unmarshal([]byte(content), &headers); err != nil {
+		return nil, err
+	}
+
+	parsed := make(map[string]string, len(headers))
+	for key, value := range headers {
+		key = http.CanonicalHeaderKey(strings.TrimSpace(key))
+		if len(key) == 0 || strings.ContainsAny(key, " :\r\n") || strings.ContainsAny(value, "\r\n") {
+			return nil, fmt.Errorf("invalid header '%s'", key)
+		}
+
+		if isReservedHeader(key) {
+			return nil, fmt.Errorf("header '%s' can't be set", key)
+		}
+
+		parsed[key] = value
+	}
+
+	return parsed, nil
+}
+
+func isReservedHeader(key string) bool {
+	if strings.HasPrefix(strings.ToUpper(key), "W_S_") {
+		return true
+	}
+
+	for _, list := range [][]string{reservedHeaders, constants.HopByHopHeaders} {
+		for _, header := range list {
+			if http.CanonicalHeaderKey(header) == key {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+//GetStaticHeaders decrypts the headers added to each delivery
+func (subscription Subscription) GetStaticHeaders() (map[string]string, error) {
+	headers := make(map[string]string)
+	if len(subscription.Headers) == 0 {
+		return headers, nil
+	}
+
+	plaintext := []byte(subscription.Headers)
+	//Headers stored before they were encrypted are plain JSON objects
+	if !isPlainStaticHeaders(subscription.Headers) {
+		var err error
+		if plaintext, err = Decrypt(subscription.Headers); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := json.Unmarshal(plaintext, &headers); err != nil {
+		return nil, err
+	}
+
+	return headers, nil
+}
+
+//GetStaticHeaderNames returns the sorted names of the headers added to each delivery
+func (subscription Subscription) GetStaticHeaderNames() ([]string, error) {
+	headers, err := subscription.GetStaticHeaders()
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+//isPlainStaticHeaders returns true if the stored headers aren't encrypted
+func isPlainStaticHeaders(headers string) bool {
+	return strings.HasPrefix(headers, "{")
+}
+
+//getAuth decrypts the auth settings of the subscription. Returns nil if no auth is set
+func (subscription Subscription) getAuth() (*DeliveryAuth, error) {
+	if len(subscription.Auth) == 0 {
+		return nil, nil
+	}
+
+	plaintext, err := Decrypt(subscription.Auth)
+	if err != nil {
+		return nil, err
+	}
+
+	var auth DeliveryAuth
+	if err = json.Unmarshal(plaintext, &auth); err != nil {
+		return nil, err
+	}
+
+	return &auth, nil
+}
+
+//getDeliveryMethod returns the method used to deliver the webhook
+func (subscription Subscription) getDeliveryMethod(webhook *Webhook) string {
+	if len(subscription.Method) > 0 {
+		return subscription.Method
+	}
+	return webhook.GetMethod()
+}
+
+//applyRequestOptions adds the static headers and credentials of the subscription to the request
+func (subscription Subscription) applyRequestOptions(req *http.Request) error {
+	headers, err := subscription.GetStaticHeaders()
+	if err != nil {
+		return err
+	}
+
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	auth, err := subscription.getAuth()
+	if err != nil {
+		return err
+	}
+
+	if auth != nil {
+		auth.apply(req)
+	}
+
+	return nil
+}
+
+//UpdateMethod sets the method used for deliveries. An empty method keeps the method of the webhook
+func (subscription *Subscription) UpdateMethod(db *dbhelper.DBhelper, method string) error {
+	_, err := db.Execf("UPDATE %s SET method=? WHERE pk_id=?", []string{TableSubscriptions}, method, subscription.PkID)
+	if err != nil {
+		return err
+	}
+
+	subscription.Method = method
+	return nil
+}
+
+//UpdateHeaders encrypts and sets the static headers added to each delivery. Values can contain credentials
+func (subscription *Subscription) UpdateHeaders(db *dbhelper.DBhelper, headers map[string]string) error {
+	var encrypted string
+	if len(headers) > 0 {
+		plaintext, err := json.Marshal(headers)
+		if err != nil {
+			return err
+		}
+
+		if encrypted, err = Encrypt(plaintext); err != nil {
+			return err
+		}
+	}
+
+	_, err := db.Execf("UPDATE %s SET headers=? WHERE pk_id=?", []string{TableSubscriptions}, encrypted, subscription.PkID)
+	if err != nil {
+		return err
+	}
+
+	subscription.Headers = encrypted
+	return nil
+}
+
+//UpdateAuth encrypts and sets the credentials sent with each delivery. nil removes them
+func (subscription *Subscription) UpdateAuth(db *dbhelper.DBhelper, auth *DeliveryAuth) error {
+	var encrypted, authType string
+	if auth != nil {
+		plaintext, err := json.Marshal(auth)
+		if err != nil {
+			return err
+		}
+
+		if encrypted, err = Encrypt(plaintext); err != nil {
+			return err
+		}
+		authType = auth.Type
+	}
+
+	_, err := db.Execf("UPDATE %s SET auth=?, authType=? WHERE pk_id=?", []string{TableSubscriptions}, encrypted, authType, subscription.PkID)
+	if err != nil {
+		return err
+	}
+
+	subscription.Auth = encrypted
+	subscription.AuthType = authType
+	return nil
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	dbhelper "github.com/JojiiOfficial/GoDBHelper"
+	"github.com/JojiiOfficial/WhShareServer/constants"
+)
+
+//SignaturePrefix prefix of each signature in the signature header
+const SignaturePrefix = "sha256="
+
+//SignDelivery returns the hex encoded HMAC-SHA256 of "<timestamp>.<payload>"
+func SignDelivery(secret, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+//GetSigningSecrets returns all secrets deliveries are currently signed with
+func (subscription Subscription) GetSigningSecrets() []string {
+	var secrets []string
+	if len(subscription.SigningSecret) > 0 {
+		secrets = append(secrets, subscription.SigningSecret)
+	}
+
+	//Sign with the old secret too until the grace period is over
+	if len(subscription.OldSigningSecret) > 0 && subscription.OldSigningExpires != nil && subscription.OldSigningExpires.After(time.Now()) {
+		secrets = append(secrets, subscription.OldSigningSecret)
+	}
+
+	return secrets
+}
+
+//signDelivery adds the timestamp and a signature for each valid secret to the header.
+//Subscribers should accept the delivery if one of the signatures matches
+func (subscription Subscription) signDelivery(header http.Header, payload []byte, now time.Time) {
+	secrets := subscription.GetSigningSecrets()
+	if len(secrets) == 0 {
+		return
+	}
+
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+
+	signatures := make([]string, len(secrets))
+	for i, secret := range secrets {
+		signatures[i] = SignaturePrefix + SignDelivery(secret, timestamp, payload)
+	}
+
+	header.Set(constants.HeaderTimestamp, timestamp)
+	header.Set(constants.HeaderSignature, strings.Join(signatures, ","))
+}
+
+//NewSigningSecret returns a hex encoded signing secret read from crypto/rand
+func NewSigningSecret() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+//RotateSigningSecret creates a new signing secret. Deliveries are signed with the old secret too until the grace period is over
+func (subscription *Subscription) RotateSigningSecret(db *dbhelper.DBhelper, gracePeriod time.Duration) error {
+	newSecret, err := NewSigningSecret()
+	if err != nil {
+		return err
+	}
+
+	expires := time.Now().Add(gracePeriod)
+
+	_, err = db.Execf("UPDATE %s SET signingSecret=?, oldSigningSecret=?, oldSigningSecretExpires=FROM_UNIXTIME(?) WHERE pk_id=?", []string{TableSubscriptions}, newSecret, subscription.SigningSecret, expires.Unix(), subscription.PkID)
+	if err != nil {
+		return err
+	}
+
+	subscription.OldSigningSecret = subscription.SigningSecret
+	subscription.OldSigningExpires = &expires
+	subscription.SigningSecret = newSecret
+	return nil
+}
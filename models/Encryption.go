@@ -0,0 +1,85 @@
+package models
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+)
+
+//Key used to encrypt credentials at rest
+var encryptionKey []byte
+
+var (
+	//ErrNoEncryptionKey error if no EncryptionKey is configured
+	ErrNoEncryptionKey = errors.New("no encryption key configured")
+	//ErrInvalidCiphertext error if an encrypted value can't be decrypted
+	ErrInvalidCiphertext = errors.New("invalid ciphertext")
+)
+
+//SetEncryptionKey sets the key used to encrypt credentials. The AES-256 key is derived from it
+func SetEncryptionKey(key string) {
+	if len(key) == 0 {
+		encryptionKey = nil
+		return
+	}
+
+	sum := sha256.Sum256([]byte(key))
+	encryptionKey = sum[:]
+}
+
+//HasEncryptionKey returns true if credentials can be encrypted
+func HasEncryptionKey() bool {
+	return len(encryptionKey) > 0
+}
+
+//Encrypt encrypts the plaintext using AES-GCM and returns it base64 encoded
+func Encrypt(plaintext []byte) (string, error) {
+	gcm, err := newGCM()
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, plaintext, nil)), nil
+}
+
+//Decrypt decrypts a value created by Encrypt
+func Decrypt(ciphertext string) ([]byte, error) {
+	gcm, err := newGCM()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil || len(data) < gcm.NonceSize() {
+		return nil, ErrInvalidCiphertext
+	}
+
+	plaintext, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, ErrInvalidCiphertext
+	}
+
+	return plaintext, nil
+}
+
+func newGCM() (cipher.AEAD, error) {
+	if !HasEncryptionKey() {
+		return nil, ErrNoEncryptionKey
+	}
+
+	block, err := aes.NewCipher(encryptionKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,306 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+//Filter a delivery filter of a subscription.
+//A filter consists of conditions combined with && and ||, where && binds stronger.
+//Conditions compare JSON paths ($.ref) or headers (header.X-Github-Event) with a value:
+//
+//	$.ref == "refs/heads/main" && header.X-Github-Event == push
+//
+//Supported operators are ==, !=, =~ (regex), !~ (negated regex).
+//A condition without operator checks if the field exists, a leading ! checks if it doesn't
+type Filter struct {
+	groups [][]filterCondition
+}
+
+type filterOperator uint8
+
+const (
+	opExists filterOperator = iota
+	opNotExists
+	opEquals
+	opNotEquals
+	opMatches
+	opNotMatches
+)
+
+//Supported filter operators
+var filterOperators = []struct {
+	token string
+	op    filterOperator
+}{
+	{"==", opEquals},
+	{"!=", opNotEquals},
+	{"=~", opMatches},
+	{"!~", opNotMatches},
+}
+
+type filterCondition struct {
+	isHeader bool
+	path     []string
+	op       filterOperator
+	value    string
+	regex    *regexp.Regexp
+}
+
+//ErrEmptyFilter error if a filter or one of its conditions is empty
+var ErrEmptyFilter = errors.New("empty filter condition")
+
+//ParseFilter parses a filter expression
+func ParseFilter(expr string) (*Filter, error) {
+	var filter Filter
+
+	for _, group := range splitOutsideQuotes(expr, "||") {
+		var conditions []filterCondition
+
+		for _, cond := range splitOutsideQuotes(group, "&&") {
+			condition, err := parseCondition(strings.TrimSpace(cond))
+			if err != nil {
+				return nil, err
+			}
+			conditions = append(conditions, *condition)
+		}
+
+		filter.groups = append(filter.groups, conditions)
+	}
+
+	return &filter, nil
+}
+
+//Matches returns true if the parsed payload and the header match the filter
+func (filter *Filter) Matches(payload interface{}, header http.Header) bool {
+	for _, group := range filter.groups {
+		matches := true
+		for _, condition := range group {
+			if !condition.matches(payload, header) {
+				matches = false
+				break
+			}
+		}
+
+		if matches {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parseCondition(cond string) (*filterCondition, error) {
+	if len(cond) == 0 {
+		return nil, ErrEmptyFilter
+	}
+
+	var condition filterCondition
+	var field string
+
+	//Find the first operator outside of the field
+	opPos := -1
+	for _, fop := range filterOperators {
+		if pos := strings.Index(cond, fop.token); pos > 0 && (opPos == -1 || pos < opPos) {
+			opPos = pos
+			condition.op = fop.op
+		}
+	}
+
+	if opPos == -1 {
+		//Existence check
+		condition.op = opExists
+		field = cond
+		if strings.HasPrefix(field, "!") {
+			condition.op = opNotExists
+			field = strings.TrimSpace(field[1:])
+		}
+	} else {
+		field = strings.TrimSpace(cond[:opPos])
+		value, err := parseFilterValue(strings.TrimSpace(cond[opPos+2:]))
+		if err != nil {
+			return nil, err
+		}
+		condition.value = value
+
+		if condition.op == opMatches || condition.op == opNotMatches {
+			condition.regex, err = regexp.Compile(value)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	switch {
+	case strings.HasPrefix(field, "header."):
+		condition.isHeader = true
+		condition.path = []string{strings.TrimPrefix(field, "header.")}
+		if len(condition.path[0]) == 0 {
+			return nil, fmt.Errorf("missing header name in '%s'", cond)
+		}
+	case field == "$":
+		condition.path = []string{}
+	case strings.HasPrefix(field, "$."):
+		path, err := parseJSONPath(field[2:])
+		if err != nil {
+			return nil, err
+		}
+		condition.path = path
+	default:
+		return nil, fmt.Errorf("invalid field '%s'. Use $.path or header.Name", field)
+	}
+
+	return &condition, nil
+}
+
+//parseJSONPath parses a path like a.b[0].c into its segments
+func parseJSONPath(path string) ([]string, error) {
+	var segments []string
+
+	for _, part := range strings.Split(path, ".") {
+		if len(part) == 0 {
+			return nil, fmt.Errorf("invalid path '%s'", path)
+		}
+
+		for len(part) > 0 {
+			pos := strings.Index(part, "[")
+			if pos == -1 {
+				segments = append(segments, part)
+				break
+			}
+
+			if pos > 0 {
+				segments = append(segments, part[:pos])
+			}
+
+			end := strings.Index(part, "]")
+			if end < pos {
+				return nil, fmt.Errorf("invalid path '%s'", path)
+			}
+
+			segments = append(segments, part[pos+1:end])
+			part = part[end+1:]
+		}
+	}
+
+	for _, segment := range segments {
+		if len(segment) == 0 {
+			return nil, fmt.Errorf("invalid path '%s'", path)
+		}
+	}
+
+	return segments, nil
+}
+
+//parseFilterValue returns the value of a quoted or bare string
+func parseFilterValue(value string) (string, error) {
+	if len(value) == 0 {
+		return "", ErrEmptyFilter
+	}
+
+	if value[0] == '"' || value[0] == '\'' {
+		if len(value) < 2 || value[len(value)-1] != value[0] {
+			return "", fmt.Errorf("unterminated string %s", value)
+		}
+		return value[1 : len(value)-1], nil
+	}
+
+	return value, nil
+}
+
+func (condition filterCondition) matches(payload interface{}, header http.Header) bool {
+	value, exists := condition.lookup(payload, header)
+
+	switch condition.op {
+	case opExists:
+		return exists
+	case opNotExists:
+		return !exists
+	case opEquals:
+		return exists && value == condition.value
+	case opNotEquals:
+		return !exists || value != condition.value
+	case opMatches:
+		return exists && condition.regex.MatchString(value)
+	case opNotMatches:
+		return !exists || !condition.regex.MatchString(value)
+	}
+
+	return false
+}
+
+//lookup returns the value of the conditions field as string
+func (condition filterCondition) lookup(payload interface{}, header http.Header) (string, bool) {
+	if condition.isHeader {
+		values, has := header[http.CanonicalHeaderKey(condition.path[0])]
+		if !has || len(values) == 0 {
+			return "", false
+		}
+		return values[0], true
+	}
+
+	current := payload
+	for _, segment := range condition.path {
+		switch v := current.(type) {
+		case map[string]interface{}:
+			next, has := v[segment]
+			if !has {
+				return "", false
+			}
+			current = next
+		case []interface{}:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(v) {
+				return "", false
+			}
+			current = v[index]
+		default:
+			return "", false
+		}
+	}
+
+	return filterValueToString(current), true
+}
+
+func filterValueToString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case nil:
+		return "null"
+	case bool, float64:
+		return fmt.Sprint(v)
+	}
+
+	b, _ := json.Marshal(value)
+	return string(b)
+}
+
+//splitOutsideQuotes splits s by sep but ignores separators within quotes
+func splitOutsideQuotes(s, sep string) []string {
+	var parts []string
+	var quote byte
+	last := 0
+
+	for i := 0; i < len(s); i++ {
+		switch {
+		case quote != 0:
+			if s[i] == quote {
+				quote = 0
+			}
+		case s[i] == '"' || s[i] == '\'':
+			quote = s[i]
+		case strings.HasPrefix(s[i:], sep):
+			parts = append(parts, s[last:i])
+			i += len(sep) - 1
+			last = i + 1
+		}
+	}
+
+	return append(parts, s[last:])
+}
@@ -0,0 +1,172 @@
+package models
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		expr   string
+		groups [][]filterCondition
+	}{
+		{"exists", `$.ref`, [][]filterCondition{
+			{{path: []string{"ref"}, op: opExists}},
+		}},
+		{"not exists", `!$.ref`, [][]filterCondition{
+			{{path: []string{"ref"}, op: opNotExists}},
+		}},
+		{"equals bare", `$.ref == main`, [][]filterCondition{
+			{{path: []string{"ref"}, op: opEquals, value: "main"}},
+		}},
+		{"equals double quoted", `$.ref=="refs/heads/main"`, [][]filterCondition{
+			{{path: []string{"ref"}, op: opEquals, value: "refs/heads/main"}},
+		}},
+		{"not equals single quoted", `$.ref != 'a b'`, [][]filterCondition{
+			{{path: []string{"ref"}, op: opNotEquals, value: "a b"}},
+		}},
+		{"header", `header.X-Github-Event == push`, [][]filterCondition{
+			{{isHeader: true, path: []string{"X-Github-Event"}, op: opEquals, value: "push"}},
+		}},
+		{"root", `$ == null`, [][]filterCondition{
+			{{path: []string{}, op: opEquals, value: "null"}},
+		}},
+		{"nested path", `$.commits[0].author.name`, [][]filterCondition{
+			{{path: []string{"commits", "0", "author", "name"}, op: opExists}},
+		}},
+		{"nested index", `$.a[1][2]`, [][]filterCondition{
+			{{path: []string{"a", "1", "2"}, op: opExists}},
+		}},
+		{"and binds stronger", `$.a && $.b || $.c`, [][]filterCondition{
+			{{path: []string{"a"}, op: opExists}, {path: []string{"b"}, op: opExists}},
+			{{path: []string{"c"}, op: opExists}},
+		}},
+		{"separators in quotes", `$.a == "x && y || z"`, [][]filterCondition{
+			{{path: []string{"a"}, op: opEquals, value: "x && y || z"}},
+		}},
+		{"first operator wins", `$.a == "b!=c"`, [][]filterCondition{
+			{{path: []string{"a"}, op: opEquals, value: "b!=c"}},
+		}},
+	}
+
+	for _, test := range tests {
+		filter, err := ParseFilter(test.expr)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err.Error())
+			continue
+		}
+
+		if !reflect.DeepEqual(filter.groups, test.groups) {
+			t.Errorf("%s: expected %+v, got %+v", test.name, test.groups, filter.groups)
+		}
+	}
+}
+
+func TestParseFilterRegex(t *testing.T) {
+	tests := []struct {
+		name  string
+		expr  string
+		op    filterOperator
+		regex string
+	}{
+		{"matches", `$.ref =~ "^refs/tags/"`, opMatches, "^refs/tags/"},
+		{"not matches", `$.ref !~ ^refs/tags/`, opNotMatches, "^refs/tags/"},
+	}
+
+	for _, test := range tests {
+		filter, err := ParseFilter(test.expr)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err.Error())
+			continue
+		}
+
+		condition := filter.groups[0][0]
+		if condition.op != test.op || condition.regex == nil || condition.regex.String() != test.regex {
+			t.Errorf("%s: expected operator %d with regex %s, got %+v", test.name, test.op, test.regex, condition)
+		}
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+	}{
+		{"empty", ``},
+		{"empty group", `$.a ||`},
+		{"empty condition", `$.a && && $.b`},
+		{"missing value", `$.a ==`},
+		{"unterminated string", `$.a == "main`},
+		{"single quote", `$.a == "`},
+		{"invalid regex", `$.a =~ "("`},
+		{"invalid field", `ref == main`},
+		{"missing header name", `header. == a`},
+		{"empty path segment", `$.a..b`},
+		{"empty index", `$.a[]`},
+		{"unclosed index", `$.a[0`},
+		{"trailing dot", `$.a.`},
+	}
+
+	for _, test := range tests {
+		if _, err := ParseFilter(test.expr); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+}
+
+func TestFilterMatches(t *testing.T) {
+	payload := `{"ref":"refs/heads/main","count":3,"draft":false,"tag":null,"commits":[{"id":"a1"},{"id":"b2"}],"repo":{"name":"server"}}`
+	header := http.Header{"X-Github-Event": []string{"push"}}
+
+	tests := []struct {
+		name    string
+		expr    string
+		matches bool
+	}{
+		{"equals", `$.ref == refs/heads/main`, true},
+		{"equals mismatch", `$.ref == refs/heads/dev`, false},
+		{"not equals", `$.ref != refs/heads/dev`, true},
+		{"not equals missing field", `$.missing != a`, true},
+		{"equals missing field", `$.missing == a`, false},
+		{"number", `$.count == 3`, true},
+		{"bool", `$.draft == false`, true},
+		{"null", `$.tag == null`, true},
+		{"object", `$.repo == '{"name":"server"}'`, true},
+		{"exists", `$.commits`, true},
+		{"exists null", `$.tag`, true},
+		{"not exists", `!$.missing`, true},
+		{"array index", `$.commits[1].id == b2`, true},
+		{"array index out of range", `$.commits[2]`, false},
+		{"array index no number", `$.commits.x`, false},
+		{"path into string", `$.ref.x`, false},
+		{"regex", `$.ref =~ ^refs/heads/`, true},
+		{"negated regex", `$.ref !~ ^refs/heads/`, false},
+		{"negated regex missing field", `$.missing !~ a`, true},
+		{"header", `header.X-GitHub-Event == push`, true},
+		{"header case insensitive", `header.x-github-event == push`, true},
+		{"missing header", `header.X-Gitlab-Event`, false},
+		{"and", `$.count == 3 && $.draft == true`, false},
+		{"or", `$.count == 4 || header.X-Github-Event == push`, true},
+		{"and before or", `$.count == 4 && $.draft == false || $.tag == null`, true},
+	}
+
+	var parsed interface{}
+	if err := json.Unmarshal([]byte(payload), &parsed); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range tests {
+		filter, err := ParseFilter(test.expr)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err.Error())
+			continue
+		}
+
+		if matches := filter.Matches(parsed, header); matches != test.matches {
+			t.Errorf("%s: expected %t, got %t", test.name, test.matches, matches)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/JojiiOfficial/WhShareServer/constants"
+)
+
+//EncodeHeader encodes a header as JSON to store it without losing values
+func EncodeHeader(header http.Header) string {
+	if header == nil {
+		header = http.Header{}
+	}
+
+	b, _ := json.Marshal(header)
+	return string(b)
+}
+
+//DecodeHeader decodes a stored header. Headers in the legacy key=value format are supported as well
+func DecodeHeader(headers string) http.Header {
+	if IsLegacyHeader(headers) {
+		return parseLegacyHeader(headers)
+	}
+
+	header := http.Header{}
+	if err := json.Unmarshal([]byte(headers), &header); err != nil {
+		return parseLegacyHeader(headers)
+	}
+
+	return header
+}
+
+//IsLegacyHeader returns true if the headers are stored in the legacy key=value format
+func IsLegacyHeader(headers string) bool {
+	return !strings.HasPrefix(strings.TrimSpace(headers), "{")
+}
+
+//GetHeader returns the stored header of the webhook
+func (webhook Webhook) GetHeader() http.Header {
+	return DecodeHeader(webhook.Headers)
+}
+
+//RemoveHopByHopHeaders removes all headers which must not be forwarded
+func RemoveHopByHopHeaders(header http.Header) {
+	//Headers listed in Connection are hop-by-hop as well
+	for _, connection := range header[http.CanonicalHeaderKey("Connection")] {
+		for _, key := range strings.Split(connection, ",") {
+			if key = strings.TrimSpace(key); len(key) > 0 {
+				header.Del(key)
+			}
+		}
+	}
+
+	for _, key := range constants.HopByHopHeaders {
+		header.Del(key)
+	}
+}
+
+//GetMethod returns the HTTP method the webhook was received with
+func (webhook Webhook) GetMethod() string {
+	//Webhooks stored before the method was recorded are always POST
+	if len(webhook.Method) == 0 {
+		return http.MethodPost
+	}
+	return webhook.Method
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	dbhelper "github.com/JojiiOfficial/GoDBHelper"
+)
+
+//ParseAllowlist parses a list of CIDR ranges. Single IPs are allowed too
+func ParseAllowlist(entries []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if len(entry) == 0 {
+			continue
+		}
+
+		//Convert single IPs into a range containing only this IP
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP '%s'", entry)
+			}
+
+			bits := 128
+			if ip.To4() != nil {
+				bits = 32
+			}
+			entry = fmt.Sprintf("%s/%d", entry, bits)
+		}
+
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, err
+		}
+
+		nets = append(nets, ipNet)
+	}
+
+	return nets, nil
+}
+
+//IsIPInAllowlist returns true if the allowlist contains the IP.
+//An empty allowlist allows every IP
+func IsIPInAllowlist(ip string, entries []string) (bool, error) {
+	nets, err := ParseAllowlist(entries)
+	if err != nil || len(nets) == 0 {
+		return len(nets) == 0, err
+	}
+
+	parsedIP := net.ParseIP(strings.TrimSpace(ip))
+	if parsedIP == nil {
+		return false, nil
+	}
+
+	for _, ipNet := range nets {
+		if ipNet.Contains(parsedIP) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+//GetAllowedIPs returns the IP ranges the source accepts webhooks from
+func (source Source) GetAllowedIPs() []string {
+	if len(source.AllowedIPs) == 0 {
+		return []string{}
+	}
+	return strings.Split(source.AllowedIPs, ",")
+}
+
+//UpdateAllowedIPs updates the IP ranges the source accepts webhooks from
+func (source *Source) UpdateAllowedIPs(db *dbhelper.DBhelper, entries []string) error {
+	source.AllowedIPs = strings.Join(entries, ",")
+	return source.Update(db, "allowedIPs", source.AllowedIPs)
+}
@@ -0,0 +1,142 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	dbhelper "github.com/JojiiOfficial/GoDBHelper"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+//JSONSchema a compiled JSON Schema document used to validate payloads.
+//Documents without $schema use draft 2020-12. Only local $refs are resolved
+//and unknown keywords or formats are ignored
+type JSONSchema struct {
+	schema *jsonschema.Schema
+}
+
+//Max count of errors returned by a validation
+const maxSchemaErrors = 20
+
+//Name of the schema document within the compiler
+const schemaResource = "schema.json"
+
+//ErrPayloadNotJSON error if a payload validated against a schema isn't JSON
+var ErrPayloadNotJSON = errors.New("payload is not valid JSON")
+
+//Compiled schemas of the sources. Schemas are compiled again if they change
+var schemaCache = struct {
+	sync.Mutex
+	schemas map[uint32]cachedSchema
+}{
+	schemas: make(map[uint32]cachedSchema),
+}
+
+type cachedSchema struct {
+	document string
+	schema   *JSONSchema
+}
+
+//ParseJSONSchema parses and compiles a JSON Schema document
+func ParseJSONSchema(schema string) (*JSONSchema, error) {
+	compiler := jsonschema.NewCompiler()
+	//Schemas must not make the server fetch other documents
+	compiler.LoadURL = func(url string) (io.ReadCloser, error) {
+		return nil, fmt.Errorf("can't load '%s': only local references are allowed", url)
+	}
+
+	if err := compiler.AddResource(schemaResource, strings.NewReader(schema)); err != nil {
+		return nil, err
+	}
+
+	compiled, err := compiler.Compile(schemaResource)
+	if err != nil {
+		return nil, err
+	}
+
+	return &JSONSchema{schema: compiled}, nil
+}
+
+//Validate validates a JSON payload and returns all violations
+func (schema *JSONSchema) Validate(payload []byte) ([]string, error) {
+	value, err := decodeJSON(payload)
+	if err != nil {
+		return nil, ErrPayloadNotJSON
+	}
+
+	err = schema.schema.Validate(value)
+	validationErr, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		return nil, err
+	}
+
+	var errs []string
+	for _, cause := range validationErr.BasicOutput().Errors {
+		//Errors of schemas containing other errors only summarize them
+		if strings.HasPrefix(cause.Error, "doesn't validate with") {
+			continue
+		}
+
+		location := cause.InstanceLocation
+		if len(location) == 0 {
+			location = "/"
+		}
+		errs = append(errs, location+": "+cause.Error)
+	}
+
+	if len(errs) > maxSchemaErrors {
+		errs = append(errs[:maxSchemaErrors], fmt.Sprintf("and %d more errors", len(errs)-maxSchemaErrors))
+	}
+
+	return errs, nil
+}
+
+//GetJSONSchema returns the parsed JSON schema of the source or nil if no schema is set.
+//The parsed schema is cached until the schema of the source changes
+func (source Source) GetJSONSchema() (*JSONSchema, error) {
+	if len(strings.TrimSpace(source.JSONSchema)) == 0 {
+		return nil, nil
+	}
+
+	schemaCache.Lock()
+	defer schemaCache.Unlock()
+
+	if cached, has := schemaCache.schemas[source.PkID]; has && cached.document == source.JSONSchema {
+		return cached.schema, nil
+	}
+
+	schema, err := ParseJSONSchema(source.JSONSchema)
+	if err != nil {
+		return nil, err
+	}
+
+	schemaCache.schemas[source.PkID] = cachedSchema{
+		document: source.JSONSchema,
+		schema:   schema,
+	}
+	return schema, nil
+}
+
+//UpdateJSONSchema sets the JSON schema of the source
+func (source *Source) UpdateJSONSchema(db *dbhelper.DBhelper, schema string) error {
+	_, err := db.Execf("UPDATE %s SET jsonSchema=? WHERE pk_id=?", []string{TableSources}, schema, source.PkID)
+	if err != nil {
+		return err
+	}
+
+	source.JSONSchema = schema
+
+	schemaCache.Lock()
+	delete(schemaCache.schemas, source.PkID)
+	schemaCache.Unlock()
+	return nil
+}
+
+//AddValidationFailure increases the count of webhooks rejected by the JSON schema of the source
+func (source *Source) AddValidationFailure(db *dbhelper.DBhelper) error {
+	_, err := db.Execf("UPDATE %s SET validationFailures=validationFailures+1 WHERE pk_id=?", []string{TableSources}, source.PkID)
+	return err
+}
@@ -0,0 +1,119 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestJSONSchemaValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  string
+		payload string
+		valid   bool
+	}{
+		{"boolean true", `true`, `{"a":1}`, true},
+		{"boolean false", `false`, `{"a":1}`, false},
+		{"type match", `{"type":"object"}`, `{}`, true},
+		{"type mismatch", `{"type":"object"}`, `[]`, false},
+		{"type list", `{"type":["string","null"]}`, `null`, true},
+		{"integer", `{"type":"integer"}`, `1.0`, true},
+		{"integer fraction", `{"type":"integer"}`, `1.5`, false},
+		{"enum match", `{"enum":["push","tag"]}`, `"push"`, true},
+		{"enum mismatch", `{"enum":["push","tag"]}`, `"issue"`, false},
+		{"const match", `{"const":{"a":[1,2]}}`, `{"a":[1,2]}`, true},
+		{"const mismatch", `{"const":{"a":[1,2]}}`, `{"a":[2,1]}`, false},
+		{"ref definitions", `{"definitions":{"id":{"type":"integer"}},"properties":{"id":{"$ref":"#/definitions/id"}}}`, `{"id":"x"}`, false},
+		{"ref defs", `{"$defs":{"id":{"type":"integer"}},"properties":{"id":{"$ref":"#/$defs/id"}}}`, `{"id":1}`, true},
+		{"properties", `{"properties":{"a":{"type":"string"}}}`, `{"a":1}`, false},
+		{"required present", `{"required":["a"]}`, `{"a":null}`, true},
+		{"required missing", `{"required":["a"]}`, `{"b":1}`, false},
+		{"additionalProperties false", `{"properties":{"a":{}},"additionalProperties":false}`, `{"a":1,"b":2}`, false},
+		{"additionalProperties schema", `{"additionalProperties":{"type":"integer"}}`, `{"a":1,"b":2}`, true},
+		{"minProperties", `{"minProperties":2}`, `{"a":1}`, false},
+		{"maxProperties", `{"maxProperties":1}`, `{"a":1}`, true},
+		{"items", `{"items":{"type":"integer"}}`, `[1,"2"]`, false},
+		{"minItems", `{"minItems":1}`, `[]`, false},
+		{"maxItems", `{"maxItems":1}`, `[1,2]`, false},
+		{"uniqueItems unique", `{"uniqueItems":true}`, `[1,"1",{"a":1}]`, true},
+		{"uniqueItems duplicate", `{"uniqueItems":true}`, `[{"a":1},{"a":1}]`, false},
+		{"minLength", `{"minLength":2}`, `"ä"`, false},
+		{"maxLength", `{"maxLength":2}`, `"äö"`, true},
+		{"pattern match", `{"pattern":"^refs/heads/"}`, `"refs/heads/main"`, true},
+		{"pattern mismatch", `{"pattern":"^refs/heads/"}`, `"refs/tags/v1"`, false},
+		{"minimum", `{"minimum":1}`, `1`, true},
+		{"maximum", `{"maximum":1}`, `2`, false},
+		{"exclusiveMinimum", `{"exclusiveMinimum":1}`, `1`, false},
+		{"exclusiveMaximum", `{"exclusiveMaximum":1}`, `0.5`, true},
+		{"multipleOf", `{"multipleOf":0.5}`, `1.5`, true},
+		{"multipleOf mismatch", `{"multipleOf":2}`, `3`, false},
+		{"allOf", `{"allOf":[{"type":"integer"},{"minimum":5}]}`, `3`, false},
+		{"anyOf", `{"anyOf":[{"type":"integer"},{"type":"string"}]}`, `"a"`, true},
+		{"oneOf match", `{"oneOf":[{"type":"integer"},{"minimum":5}]}`, `3`, true},
+		{"oneOf both", `{"oneOf":[{"type":"integer"},{"minimum":5}]}`, `7`, false},
+		{"not", `{"not":{"type":"null"}}`, `null`, false},
+		{"format", `{"type":"string","format":"email"}`, `"user@example.com"`, true},
+		{"unknown format", `{"type":"string","format":"custom"}`, `"value"`, true},
+		{"patternProperties", `{"patternProperties":{"^x-":{"type":"integer"}}}`, `{"x-a":"b"}`, false},
+		{"if then else", `{"if":{"properties":{"a":{"const":1}}},"then":{"required":["b"]},"else":{"required":["c"]}}`, `{"a":1,"c":1}`, false},
+		{"dependentRequired", `{"dependentRequired":{"a":["b"]}}`, `{"a":1}`, false},
+		{"unknown keyword", `{"x-custom":true,"type":"object"}`, `{}`, true},
+		{"deeply nested payload", `{"type":"array"}`, strings.Repeat("[", 100) + strings.Repeat("]", 100), true},
+		{"annotations", `{"$schema":"http://json-schema.org/draft-07/schema#","title":"t","description":"d","type":"object"}`, `{}`, true},
+	}
+
+	for _, test := range tests {
+		schema, err := ParseJSONSchema(test.schema)
+		if err != nil {
+			t.Errorf("%s: unexpected parse error: %s", test.name, err.Error())
+			continue
+		}
+
+		errs, err := schema.Validate([]byte(test.payload))
+		if err != nil {
+			t.Errorf("%s: unexpected validation error: %s", test.name, err.Error())
+			continue
+		}
+
+		if valid := len(errs) == 0; valid != test.valid || (!valid && len(errs[0]) == 0) {
+			t.Errorf("%s: expected valid=%t, got errors %v", test.name, test.valid, errs)
+		}
+	}
+}
+
+func TestParseJSONSchemaErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+	}{
+		{"invalid JSON", `{`},
+		{"no object", `"string"`},
+		{"unknown type", `{"type":"text"}`},
+		{"invalid enum", `{"enum":"a"}`},
+		{"invalid required", `{"required":[1]}`},
+		{"invalid pattern", `{"pattern":"("}`},
+		{"empty anyOf", `{"anyOf":[]}`},
+		{"invalid multipleOf", `{"multipleOf":0}`},
+		{"negative length", `{"minLength":-1}`},
+		{"fractional length", `{"maxItems":1.5}`},
+		{"unresolved ref", `{"$ref":"#/definitions/missing"}`},
+		{"remote ref", `{"$ref":"https://example.com/schema.json"}`},
+	}
+
+	for _, test := range tests {
+		if _, err := ParseJSONSchema(test.schema); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+}
+
+func TestJSONSchemaPayloadNotJSON(t *testing.T) {
+	schema, err := ParseJSONSchema(`{"type":"object"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = schema.Validate([]byte("a=b")); err != ErrPayloadNotJSON {
+		t.Errorf("expected ErrPayloadNotJSON, got %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package models
+
+import (
+	dbhelper "github.com/JojiiOfficial/GoDBHelper"
+)
+
+//TableOrderedQueue table containing the webhooks ordered subscriptions didn't receive yet
+const TableOrderedQueue = "OrderedQueue"
+
+//UpdateOrdered enables or disables ordered delivery. Ordered subscriptions start with the next webhook handed over by the outbox
+func (subscription *Subscription) UpdateOrdered(db *dbhelper.DBhelper, ordered bool) error {
+	_, err := db.Execf("UPDATE %s SET ordered=? WHERE pk_id=?", []string{TableSubscriptions}, ordered, subscription.PkID)
+	if err != nil {
+		return err
+	}
+
+	subscription.Ordered = ordered
+	if ordered {
+		return nil
+	}
+
+	//Webhooks queued while the subscription was ordered aren't delivered anymore
+	_, err = db.Execf("DELETE FROM %s WHERE subscriptionID=?", []string{TableOrderedQueue}, subscription.PkID)
+	return err
+}
+
+//enqueueOrdered appends the webhook to the queue of the ordered subscription.
+//Webhooks which are queued already are ignored, so the outbox can hand a webhook over again
+func (subscription Subscription) enqueueOrdered(db *dbhelper.DBhelper, webhookPK uint32) error {
+	_, err := db.Execf("INSERT IGNORE INTO %s (subscriptionID, webhookID) VALUES (?,?)", []string{TableOrderedQueue}, subscription.PkID, webhookPK)
+	return err
+}
+
+//RemoveOrderedWebhook removes a delivered webhook from the queue of the ordered subscription
+func (subscription Subscription) RemoveOrderedWebhook(db *dbhelper.DBhelper, webhookPK uint32) error {
+	_, err := db.Execf("DELETE FROM %s WHERE subscriptionID=? AND webhookID=?", []string{TableOrderedQueue}, subscription.PkID, webhookPK)
+	return err
+}
+
+//GetNextOrderedWebhook returns the oldest webhook queued for the ordered subscription.
+//The queue is filled in the order the outbox hands the webhooks over.
+//Returns nil if the subscription received all webhooks
+func (subscription *Subscription) GetNextOrderedWebhook(db *dbhelper.DBhelper) (*Webhook, error) {
+	for {
+		var webhooks []Webhook
+		err := db.QueryRowsf(&webhooks, "SELECT %s.* FROM %s JOIN %s ON %s.pk_id = %s.webhookID WHERE %s.subscriptionID=? ORDER BY %s.pk_id ASC LIMIT 1",
+			[]string{TableWebhooks, TableOrderedQueue, TableWebhooks, TableWebhooks, TableOrderedQueue, TableOrderedQueue, TableOrderedQueue}, subscription.PkID)
+		if err != nil || len(webhooks) == 0 {
+			return nil, err
+		}
+
+		if webhooks[0].IsAcceptedBy(*subscription) {
+			return &webhooks[0], nil
+		}
+
+		//Skip webhooks the subscription doesn't want anymore
+		if err = subscription.RemoveOrderedWebhook(db, webhooks[0].PkID); err != nil {
+			return nil, err
+		}
+	}
+}
+
+//ResumeOrderedDeliveries continues the delivery to the ordered subscriptions of the source.
+//Their deliveries are dropped while the source is paused
+func ResumeOrderedDeliveries(db *dbhelper.DBhelper, scheduler DeliveryScheduler, source *Source) error {
+	subscriptions, err := source.getSubscriptions(db)
+	if err != nil {
+		return err
+	}
+
+	var deliveries []Delivery
+	for i := range subscriptions {
+		if subscriptions[i].Ordered {
+			deliveries = append(deliveries, Delivery{
+				Source:       source,
+				Subscription: &subscriptions[i],
+				Ordered:      true,
+			})
+		}
+	}
+
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	return scheduler.Schedule(deliveries...)
+}
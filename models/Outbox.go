@@ -0,0 +1,78 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	dbhelper "github.com/JojiiOfficial/GoDBHelper"
+)
+
+//OutboxEntry a stored webhook which wasn't handed over to the subscribers yet
+type OutboxEntry struct {
+	PkID      uint32    `db:"pk_id" orm:"pk,ai"`
+	WebhookPK uint32    `db:"webhookID"`
+	Created   time.Time `db:"created"`
+
+	ExcludedSubscription uint32 `db:"excludedSubscription"`
+}
+
+//TableOutbox table containing the outbox entries
+const TableOutbox = "WebhookOutbox"
+
+//InsertWithOutbox inserts the webhook and its outbox entry in one transaction.
+//The excluded subscription won't be notified by the outbox. Webhooks with a delivery ID
+//are deduplicated within dedupWindow. A window of 0 disables the deduplication
+func (webhook *Webhook) InsertWithOutbox(db *dbhelper.DBhelper, excludedSubscription uint32, dedupWindow time.Duration) error {
+	tx, err := db.DB.Beginx()
+	if err != nil {
+		return err
+	}
+
+	if len(webhook.DeliveryID) > 0 && dedupWindow > 0 {
+		if err = webhook.claimDeliveryID(tx, dedupWindow); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	res, err := tx.Exec(fmt.Sprintf("INSERT INTO %s (sourceID, header, method, query, payload, eventType, deliveryID) VALUES (?,?,?,?,?,?,?)", TableWebhooks),
+		webhook.SourceID, webhook.Headers, webhook.Method, webhook.Query, webhook.Payload, webhook.EventType, webhook.DeliveryID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec(fmt.Sprintf("INSERT INTO %s (webhookID, excludedSubscription) VALUES (?,?)", TableOutbox), id, excludedSubscription)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	webhook.PkID = uint32(id)
+	webhook.Received = time.Now()
+	return nil
+}
+
+//GetOutboxEntries returns the oldest outbox entries. Entries of paused sources are kept back
+func GetOutboxEntries(db *dbhelper.DBhelper, limit int) ([]OutboxEntry, error) {
+	var entries []OutboxEntry
+	err := db.QueryRowsf(&entries, "SELECT %s.* FROM %s JOIN %s ON %s.pk_id = %s.webhookID JOIN %s ON %s.pk_id = %s.sourceID WHERE %s.paused = 0 ORDER BY %s.pk_id ASC LIMIT ?",
+		[]string{TableOutbox, TableOutbox, TableWebhooks, TableWebhooks, TableOutbox, TableSources, TableSources, TableWebhooks, TableSources, TableOutbox}, limit)
+	return entries, err
+}
+
+//Delete deletes the outbox entry
+func (entry OutboxEntry) Delete(db *dbhelper.DBhelper) error {
+	_, err := db.Execf("DELETE FROM %s WHERE pk_id=?", []string{TableOutbox}, entry.PkID)
+	return err
+}
@@ -0,0 +1,138 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/url"
+	"strings"
+)
+
+//PayloadKind the kind of a webhook payload
+type PayloadKind uint8
+
+//Payload kinds
+const (
+	RawPayload PayloadKind = iota
+	JSONPayload
+	FormPayload
+)
+
+//GetPayloadKind returns the kind of the payload by its content type.
+//If no content type is given, the payload gets sniffed
+func GetPayloadKind(contentType string, payload []byte) PayloadKind {
+	if len(strings.TrimSpace(contentType)) == 0 {
+		if json.Valid(payload) {
+			return JSONPayload
+		}
+		return RawPayload
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return RawPayload
+	}
+
+	switch {
+	case mediaType == "application/json", strings.HasSuffix(mediaType, "+json"):
+		return JSONPayload
+	case mediaType == "application/x-www-form-urlencoded":
+		return FormPayload
+	}
+
+	return RawPayload
+}
+
+//ParsePayload parses the payload depending on its kind.
+//Returns the decoded JSON, the form values or the payload as string
+func ParsePayload(contentType string, payload []byte) interface{} {
+	switch GetPayloadKind(contentType, payload) {
+	case JSONPayload:
+		var parsed interface{}
+		if json.Unmarshal(payload, &parsed) == nil {
+			return parsed
+		}
+	case FormPayload:
+		values, err := url.ParseQuery(string(payload))
+		if err == nil {
+			form := make(map[string]interface{})
+			for key := range values {
+				form[key] = values.Get(key)
+			}
+			return form
+		}
+	}
+
+	return string(payload)
+}
+
+//EditForm calls edit for each field of a form body. edit returns the new value of the field
+//and false if the field should be removed. The order of the fields is kept and the original
+//payload is returned if nothing was changed
+func EditForm(payload []byte, edit func(key, value string) (string, bool)) ([]byte, error) {
+	fields := strings.Split(string(payload), "&")
+	edited := make([]string, 0, len(fields))
+	changed := false
+
+	for _, field := range fields {
+		if len(field) == 0 {
+			edited = append(edited, field)
+			continue
+		}
+
+		rawKey, rawValue := field, ""
+		if pos := strings.Index(field, "="); pos > -1 {
+			rawKey, rawValue = field[:pos], field[pos+1:]
+		}
+
+		key, err := url.QueryUnescape(rawKey)
+		if err != nil {
+			return nil, err
+		}
+		value, err := url.QueryUnescape(rawValue)
+		if err != nil {
+			return nil, err
+		}
+
+		newValue, keep := edit(key, value)
+		switch {
+		case !keep:
+			changed = true
+		case newValue != value:
+			edited = append(edited, rawKey+"="+url.QueryEscape(newValue))
+			changed = true
+		default:
+			edited = append(edited, field)
+		}
+	}
+
+	if !changed {
+		return payload, nil
+	}
+
+	return []byte(strings.Join(edited, "&")), nil
+}
+
+//decodeJSON decodes the payload and keeps numbers as they are
+func decodeJSON(payload []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+
+	var parsed interface{}
+	if err := decoder.Decode(&parsed); err != nil {
+		return nil, err
+	}
+	return parsed, nil
+}
+
+//encodeJSON encodes the value without escaping HTML characters
+func encodeJSON(value interface{}) ([]byte, error) {
+	var buff bytes.Buffer
+	encoder := json.NewEncoder(&buff)
+	encoder.SetEscapeHTML(false)
+
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buff.Bytes(), []byte("\n")), nil
+}
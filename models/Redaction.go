@@ -0,0 +1,231 @@
+package models
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	dbhelper "github.com/JojiiOfficial/GoDBHelper"
+)
+
+//RedactionMask the value masked fields get replaced with
+const RedactionMask = "***"
+
+//RedactionRules rules to remove sensitive data from webhooks of a source.
+//Paths are JSON paths like $.a.b[0].c where [*] matches all items of an array.
+//Headers are header names or regular expressions wrapped in slashes like /^X-Token-.*$/
+type RedactionRules struct {
+	DeletePaths []string `json:"delete,omitempty"`
+	MaskPaths   []string `json:"mask,omitempty"`
+	Headers     []string `json:"headers,omitempty"`
+
+	deletePaths [][]string
+	maskPaths   [][]string
+	headerNames []string
+	headerRegex []*regexp.Regexp
+}
+
+//ParseRedactionRules parses and validates redaction rules in JSON format
+func ParseRedactionRules(rules string) (*RedactionRules, error) {
+	var redactionRules RedactionRules
+	if len(strings.TrimSpace(rules)) == 0 {
+		return &redactionRules, nil
+	}
+
+	if err := json.Unmarshal([]byte(rules), &redactionRules); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if redactionRules.deletePaths, err = parseRedactionPaths(redactionRules.DeletePaths); err != nil {
+		return nil, err
+	}
+	if redactionRules.maskPaths, err = parseRedactionPaths(redactionRules.MaskPaths); err != nil {
+		return nil, err
+	}
+
+	for _, header := range redactionRules.Headers {
+		header = strings.TrimSpace(header)
+		if len(header) > 2 && strings.HasPrefix(header, "/") && strings.HasSuffix(header, "/") {
+			regex, err := regexp.Compile("(?i)" + header[1:len(header)-1])
+			if err != nil {
+				return nil, err
+			}
+			redactionRules.headerRegex = append(redactionRules.headerRegex, regex)
+		} else if len(header) > 0 {
+			redactionRules.headerNames = append(redactionRules.headerNames, http.CanonicalHeaderKey(header))
+		}
+	}
+
+	return &redactionRules, nil
+}
+
+func parseRedactionPaths(paths []string) ([][]string, error) {
+	var parsed [][]string
+	for _, path := range paths {
+		segments, err := parseJSONPath(strings.TrimPrefix(strings.TrimSpace(path), "$."))
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, segments)
+	}
+	return parsed, nil
+}
+
+//IsEmpty return true if the rules don't redact anything
+func (rules *RedactionRules) IsEmpty() bool {
+	return len(rules.deletePaths) == 0 && len(rules.maskPaths) == 0 && len(rules.headerNames) == 0 && len(rules.headerRegex) == 0
+}
+
+//RedactHeader removes all headers matching the rules
+func (rules *RedactionRules) RedactHeader(header http.Header) {
+	for key := range header {
+		if rules.isHeaderRedacted(key) {
+			header.Del(key)
+		}
+	}
+}
+
+func (rules *RedactionRules) isHeaderRedacted(key string) bool {
+	key = http.CanonicalHeaderKey(key)
+	for _, name := range rules.headerNames {
+		if name == key {
+			return true
+		}
+	}
+
+	for _, regex := range rules.headerRegex {
+		if regex.MatchString(key) {
+			return true
+		}
+	}
+
+	return false
+}
+
+//RedactPayload deletes and masks the fields of JSON objects and form bodies matching the rules.
+//Other payloads and payloads without matching fields are returned unchanged
+func (rules *RedactionRules) RedactPayload(contentType string, payload []byte) ([]byte, error) {
+	if len(rules.deletePaths) == 0 && len(rules.maskPaths) == 0 {
+		return payload, nil
+	}
+
+	switch GetPayloadKind(contentType, payload) {
+	case JSONPayload:
+		parsed, err := decodeJSON(payload)
+		if err != nil {
+			return nil, err
+		}
+
+		changed := false
+		for _, path := range rules.deletePaths {
+			changed = redactJSON(parsed, path, true) || changed
+		}
+		for _, path := range rules.maskPaths {
+			changed = redactJSON(parsed, path, false) || changed
+		}
+
+		if !changed {
+			return payload, nil
+		}
+
+		return encodeJSON(parsed)
+	case FormPayload:
+		//Form bodies have no nested fields
+		return EditForm(payload, func(key, value string) (string, bool) {
+			for _, path := range rules.deletePaths {
+				if len(path) == 1 && path[0] == key {
+					return value, false
+				}
+			}
+			for _, path := range rules.maskPaths {
+				if len(path) == 1 && path[0] == key {
+					return RedactionMask, true
+				}
+			}
+			return value, true
+		})
+	}
+
+	return payload, nil
+}
+
+//redactJSON deletes or masks the value at the path. Returns true if a value was redacted
+func redactJSON(current interface{}, path []string, remove bool) bool {
+	if len(path) == 0 {
+		return false
+	}
+
+	segment, last := path[0], len(path) == 1
+
+	switch v := current.(type) {
+	case map[string]interface{}:
+		next, has := v[segment]
+		if !has {
+			return false
+		}
+
+		if last {
+			if remove {
+				delete(v, segment)
+			} else {
+				v[segment] = RedactionMask
+			}
+			return true
+		}
+
+		return redactJSON(next, path[1:], remove)
+	case []interface{}:
+		var indices []int
+		if segment == "*" {
+			for i := range v {
+				indices = append(indices, i)
+			}
+		} else if index, err := strconv.Atoi(segment); err == nil && index >= 0 && index < len(v) {
+			indices = []int{index}
+		}
+
+		changed := false
+		for _, index := range indices {
+			if last {
+				//Items of arrays can't be deleted without changing the indices. Mask them instead
+				v[index] = RedactionMask
+				changed = true
+			} else {
+				changed = redactJSON(v[index], path[1:], remove) || changed
+			}
+		}
+		return changed
+	}
+
+	return false
+}
+
+//GetRedactionRules returns the parsed redaction rules of the source
+func (source Source) GetRedactionRules() (*RedactionRules, error) {
+	return ParseRedactionRules(source.Redaction)
+}
+
+//Redact removes the headers and payload fields matching the redaction rules of the source
+func (source Source) Redact(header http.Header, payload []byte) ([]byte, error) {
+	rules, err := source.GetRedactionRules()
+	if err != nil {
+		return nil, err
+	}
+
+	rules.RedactHeader(header)
+	return rules.RedactPayload(header.Get("Content-Type"), payload)
+}
+
+//UpdateRedaction sets the redaction rules of the source
+func (source *Source) UpdateRedaction(db *dbhelper.DBhelper, rules string) error {
+	_, err := db.Execf("UPDATE %s SET redaction=? WHERE pk_id=?", []string{TableSources}, rules, source.PkID)
+	if err != nil {
+		return err
+	}
+
+	source.Redaction = rules
+	return nil
+}
@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 //CredentialRequest request containing credentials
 type CredentialRequest struct {
 	Username string `json:"username"`
@@ -31,8 +33,56 @@ type SubscriptionUpdateCallbackRequest struct {
 	CallbackURL    string `json:"cbUrl"`
 }
 
+//SubscriptionUpdateRequest request for updating a subscription
+type SubscriptionUpdateRequest struct {
+	SubscriptionID string `json:"subID"`
+	Content        string `json:"content"`
+	ContentType    string `json:"contentType,omitempty"`
+}
+
 //SourceRequest request containing sourceData
 type SourceRequest struct {
 	SourceID string `json:"sid,omitempty"`
 	Content  string `json:"content,omitempty"`
 }
+
+//WebhookReplayRequest request to replay a webhook of a source
+type WebhookReplayRequest struct {
+	SourceID       string `json:"sid"`
+	WebhookID      uint32 `json:"webhookID"`
+	SubscriptionID string `json:"subID,omitempty"`
+}
+
+//SubscriptionReplayRequest request to replay webhooks to a subscription.
+//Either a single webhook or all webhooks since the given duration are replayed
+type SubscriptionReplayRequest struct {
+	SubscriptionID string `json:"subID"`
+	WebhookID      uint32 `json:"webhookID,omitempty"`
+	Since          string `json:"since,omitempty"`
+}
+
+//WebhookListRequest request to list the webhooks of a source.
+//Webhooks are listed from new to old, Cursor is the nextCursor of the previous page
+type WebhookListRequest struct {
+	SourceID  string     `json:"sid"`
+	Cursor    uint32     `json:"cursor,omitempty"`
+	Limit     int        `json:"limit,omitempty"`
+	From      *time.Time `json:"from,omitempty"`
+	To        *time.Time `json:"to,omitempty"`
+	EventType string     `json:"eventType,omitempty"`
+}
+
+//WebhookRequest request to get a webhook of a source
+type WebhookRequest struct {
+	SourceID  string `json:"sid"`
+	WebhookID uint32 `json:"webhookID"`
+}
+
+//DeliveryAttemptsRequest request to list delivery attempts of a source or subscription
+type DeliveryAttemptsRequest struct {
+	SourceID       string `json:"sid,omitempty"`
+	SubscriptionID string `json:"subID,omitempty"`
+	WebhookID      uint32 `json:"webhookID,omitempty"`
+	Cursor         uint32 `json:"cursor,omitempty"`
+	Limit          int    `json:"limit,omitempty"`
+}
@@ -1,5 +1,10 @@
 package models
 
+import (
+	"net/http"
+	"time"
+)
+
 const (
 	//NotFoundError error from server
 	NotFoundError string = "Not found"
@@ -55,13 +60,73 @@ type SourceAddResponse struct {
 
 //SubscriptionResponse response for subscription
 type SubscriptionResponse struct {
-	Message        string `json:"message,omitempty"`
-	SubscriptionID string `json:"sid"`
-	Name           string `json:"name"`
-	Mode           uint8  `json:"mode"`
+	Message        string   `json:"message,omitempty"`
+	SubscriptionID string   `json:"sid"`
+	Name           string   `json:"name"`
+	Mode           uint8    `json:"mode"`
+	EventTypes     []string `json:"eventTypes,omitempty"`
+	SigningSecret  string   `json:"signingSecret,omitempty"`
+	Method         string   `json:"method,omitempty"`
+	Headers        []string `json:"headers,omitempty"`
+	AuthType       string   `json:"authType,omitempty"`
+	Timeout        uint32   `json:"timeout,omitempty"`
+	MaxAttempts    uint8    `json:"maxAttempts,omitempty"`
+	Backoff        string   `json:"backoff,omitempty"`
+	Ordered        bool     `json:"ordered,omitempty"`
 }
 
 //ListSourcesResponse response containing a list of sources
 type ListSourcesResponse struct {
 	Sources []Source `json:"sources,omitempty"`
 }
+
+//ModeResponse a mode available for sources
+type ModeResponse struct {
+	ID   uint8  `json:"id"`
+	Name string `json:"name"`
+}
+
+//ModeListResponse response for listing modes
+type ModeListResponse struct {
+	Modes []ModeResponse `json:"modes"`
+}
+
+//ReplayResponse response for replaying webhooks
+type ReplayResponse struct {
+	Count int `json:"count"`
+}
+
+//WebhookInfo summary of a stored webhook
+type WebhookInfo struct {
+	ID          uint32    `json:"id"`
+	Received    time.Time `json:"received"`
+	EventType   string    `json:"eventType,omitempty"`
+	DeliveryID  string    `json:"deliveryID,omitempty"`
+	Method      string    `json:"method"`
+	PayloadSize int       `json:"payloadSize"`
+}
+
+//WebhookListResponse response for listing webhooks
+type WebhookListResponse struct {
+	Webhooks   []WebhookInfo `json:"webhooks"`
+	NextCursor uint32        `json:"nextCursor,omitempty"`
+}
+
+//WebhookResponse response containing a stored webhook
+type WebhookResponse struct {
+	WebhookInfo
+	Query   string      `json:"query,omitempty"`
+	Headers http.Header `json:"headers"`
+	Payload string      `json:"payload"`
+}
+
+//DeliveryAttemptsResponse response for listing delivery attempts
+type DeliveryAttemptsResponse struct {
+	Attempts   []DeliveryAttempt `json:"attempts"`
+	NextCursor uint32            `json:"nextCursor,omitempty"`
+}
+
+//SchemaValidationResponse response for webhooks rejected by the JSON schema of the source
+type SchemaValidationResponse struct {
+	Errors []string `json:"errors"`
+}
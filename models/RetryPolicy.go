@@ -0,0 +1,143 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	dbhelper "github.com/JojiiOfficial/GoDBHelper"
+)
+
+//DefaultDeliveryTimeout timeout of deliveries if the subscription doesn't override it
+const DefaultDeliveryTimeout = 20 * time.Second
+
+//DeliveryLimits limits for the delivery settings of subscriptions
+type DeliveryLimits struct {
+	MaxTimeout  time.Duration
+	MaxAttempts int
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+}
+
+//GetDeliveryLimits returns the limits for subscriptions of users without role specific limits
+func (config *ConfigStruct) GetDeliveryLimits() DeliveryLimits {
+	return DeliveryLimits{
+		MaxTimeout:  config.Server.MaxDeliveryTimeout,
+		MaxAttempts: config.Server.Retries.MaxAttempts,
+		MinBackoff:  config.Server.Retries.MinBackoff,
+		MaxBackoff:  config.Server.Retries.MaxBackoff,
+	}
+}
+
+//ValidateTimeout returns an error if the timeout exceeds the limits
+func (limits DeliveryLimits) ValidateTimeout(timeout time.Duration) error {
+	if timeout < time.Second || timeout > limits.MaxTimeout {
+		return fmt.Errorf("timeout has to be between 1s and %s", limits.MaxTimeout.String())
+	}
+	return nil
+}
+
+//ValidateMaxAttempts returns an error if the count of attempts exceeds the limits
+func (limits DeliveryLimits) ValidateMaxAttempts(attempts int) error {
+	if attempts < 1 || attempts > limits.MaxAttempts {
+		return fmt.Errorf("max attempts have to be between 1 and %d", limits.MaxAttempts)
+	}
+	return nil
+}
+
+//ParseBackoff parses a backoff schedule of durations separated by ',' like "30s,5m,1h"
+func (limits DeliveryLimits) ParseBackoff(content string) ([]time.Duration, error) {
+	var backoff []time.Duration
+	for _, item := range strings.Split(content, ",") {
+		duration, err := time.ParseDuration(strings.TrimSpace(item))
+		if err != nil {
+			return nil, err
+		}
+
+		if duration < limits.MinBackoff || duration > limits.MaxBackoff {
+			return nil, fmt.Errorf("backoff has to be between %s and %s", limits.MinBackoff.String(), limits.MaxBackoff.String())
+		}
+
+		backoff = append(backoff, duration)
+	}
+
+	if len(backoff) == 0 || len(backoff) > limits.MaxAttempts {
+		return nil, errors.New("invalid count of backoff steps")
+	}
+
+	return backoff, nil
+}
+
+//GetTimeout returns the timeout for deliveries to the subscription
+func (subscription Subscription) GetTimeout() time.Duration {
+	if subscription.Timeout > 0 {
+		return time.Duration(subscription.Timeout) * time.Second
+	}
+	return DefaultDeliveryTimeout
+}
+
+//GetBackoff returns the backoff schedule of the subscription. nil if the default schedule is used
+func (subscription Subscription) GetBackoff() []time.Duration {
+	var backoff []time.Duration
+	for _, item := range strings.Split(subscription.Backoff, ",") {
+		if duration, err := time.ParseDuration(strings.TrimSpace(item)); err == nil {
+			backoff = append(backoff, duration)
+		}
+	}
+	return backoff
+}
+
+//GetMaxAttempts returns the count of delivery attempts before the subscription gets removed.
+//defaultAttempts is used if neither max attempts nor a backoff schedule is set
+func (subscription Subscription) GetMaxAttempts(defaultAttempts int) int {
+	if subscription.MaxAttempts > 0 {
+		return int(subscription.MaxAttempts)
+	}
+
+	//One attempt for each step of the backoff plus the first delivery
+	if backoff := subscription.GetBackoff(); len(backoff) > 0 {
+		return len(backoff) + 1
+	}
+
+	return defaultAttempts
+}
+
+//UpdateTimeout sets the delivery timeout. 0 uses the default timeout
+func (subscription *Subscription) UpdateTimeout(db *dbhelper.DBhelper, timeout time.Duration) error {
+	seconds := uint32(timeout / time.Second)
+	_, err := db.Execf("UPDATE %s SET timeout=? WHERE pk_id=?", []string{TableSubscriptions}, seconds, subscription.PkID)
+	if err != nil {
+		return err
+	}
+
+	subscription.Timeout = seconds
+	return nil
+}
+
+//UpdateMaxAttempts sets the max count of delivery attempts. 0 uses the default
+func (subscription *Subscription) UpdateMaxAttempts(db *dbhelper.DBhelper, attempts uint8) error {
+	_, err := db.Execf("UPDATE %s SET maxAttempts=? WHERE pk_id=?", []string{TableSubscriptions}, attempts, subscription.PkID)
+	if err != nil {
+		return err
+	}
+
+	subscription.MaxAttempts = attempts
+	return nil
+}
+
+//UpdateBackoff sets the backoff schedule. An empty schedule uses the default
+func (subscription *Subscription) UpdateBackoff(db *dbhelper.DBhelper, backoff []time.Duration) error {
+	steps := make([]string, len(backoff))
+	for i := range backoff {
+		steps[i] = backoff[i].String()
+	}
+
+	_, err := db.Execf("UPDATE %s SET backoff=? WHERE pk_id=?", []string{TableSubscriptions}, strings.Join(steps, ","), subscription.PkID)
+	if err != nil {
+		return err
+	}
+
+	subscription.Backoff = strings.Join(steps, ",")
+	return nil
+}
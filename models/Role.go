@@ -1,6 +1,8 @@
 package models
 
 import (
+	"time"
+
 	dbhelper "github.com/JojiiOfficial/GoDBHelper"
 )
 
@@ -14,6 +16,12 @@ type Role struct {
 	MaxHookCalls     int    `db:"maxHookCalls"`
 	MaxTraffic       int    `db:"maxTraffic"`
 	IsAdmin          bool   `db:"isAdmin"`
+	MaxHookRate      int    `db:"maxHookRate"`
+	MaxHookBurst     int    `db:"maxHookBurst"`
+
+	MaxDeliveryTimeout int `db:"maxDeliveryTimeout"`
+	MaxRetryAttempts   int `db:"maxRetryAttempts"`
+	MinRetryBackoff    int `db:"minRetryBackoff"`
 }
 
 //TableRoles the db tableName for the roles
@@ -61,3 +69,35 @@ func (user User) IsSubscriptionLimitReached(db *dbhelper.DBhelper) (bool, error)
 func (user User) CanSubscribe() bool {
 	return user.Role.MaxSubscriptions != 0
 }
+
+//GetHookRateLimit returns the webhooks per minute and the burst a users sources can receive.
+//Values of 0 use the given defaults, -1 is unlimited
+func (user User) GetHookRateLimit(defaultRate, defaultBurst int) (int, int) {
+	rate, burst := user.Role.MaxHookRate, user.Role.MaxHookBurst
+	if rate == 0 {
+		rate = defaultRate
+	}
+	if burst == 0 {
+		burst = defaultBurst
+	}
+	return rate, burst
+}
+
+//GetDeliveryLimits returns the limits for the delivery settings of a users subscriptions.
+//Values of 0 use the given defaults
+func (user User) GetDeliveryLimits(defaults DeliveryLimits) DeliveryLimits {
+	limits := defaults
+	if user.Role.MaxDeliveryTimeout > 0 {
+		limits.MaxTimeout = time.Duration(user.Role.MaxDeliveryTimeout) * time.Second
+	}
+	if user.Role.MaxRetryAttempts > 0 {
+		limits.MaxAttempts = user.Role.MaxRetryAttempts
+	}
+	if user.Role.MinRetryBackoff > 0 {
+		limits.MinBackoff = time.Duration(user.Role.MinRetryBackoff) * time.Second
+	}
+	if limits.MaxBackoff < limits.MinBackoff {
+		limits.MaxBackoff = limits.MinBackoff
+	}
+	return limits
+}
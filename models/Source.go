@@ -9,16 +9,27 @@ import (
 
 //Source a webhook source
 type Source struct {
-	PkID         uint32    `db:"pk_id" orm:"pk,ai" json:"-"`
-	Name         string    `db:"name" json:"name"`
-	SourceID     string    `db:"sourceID" json:"sourceID"`
-	Description  string    `db:"description" json:"description"`
-	Secret       string    `db:"secret" json:"secret"`
-	CreatorID    uint32    `db:"creator" json:"-"`
-	CreationTime time.Time `db:"creationTime" json:"crTime"`
-	IsPrivate    bool      `db:"private" json:"isPrivate"`
-	Mode         uint8     `db:"mode" json:"mode"`
-	Creator      User      `db:"-" orm:"-" json:"-"`
+	PkID               uint32     `db:"pk_id" orm:"pk,ai" json:"-"`
+	Name               string     `db:"name" json:"name"`
+	SourceID           string     `db:"sourceID" json:"sourceID"`
+	Description        string     `db:"description" json:"description"`
+	Secret             string     `db:"secret" json:"secret"`
+	OldSecret          string     `db:"oldSecret" json:"-"`
+	OldExpires         *time.Time `db:"oldSecretExpires" orm:"-" json:"-"`
+	CreatorID          uint32     `db:"creator" json:"-"`
+	CreationTime       time.Time  `db:"creationTime" json:"crTime"`
+	IsPrivate          bool       `db:"private" json:"isPrivate"`
+	Mode               uint8      `db:"mode" json:"mode"`
+	Duplicates         uint32     `db:"duplicates" json:"duplicates,omitempty"`
+	AllowedIPs         string     `db:"allowedIPs" json:"allowedIPs,omitempty"`
+	Redaction          string     `db:"redaction" json:"redaction,omitempty"`
+	SyncSubsPK         uint32     `db:"syncSubscription" json:"-"`
+	IsPaused           bool       `db:"paused" json:"isPaused,omitempty"`
+	JSONSchema         string     `db:"jsonSchema" json:"jsonSchema,omitempty"`
+	ValidationFailures uint32     `db:"validationFailures" json:"validationFailures,omitempty"`
+	HashDeduplication  bool       `db:"hashDeduplication" json:"hashDeduplication,omitempty"`
+	Creator            User       `db:"-" orm:"-" json:"-"`
+	EventTypes         []string   `db:"-" orm:"-" json:"eventTypes,omitempty"`
 }
 
 //TableSources the db tableName for sources
@@ -67,6 +78,101 @@ func (source *Source) Insert(db *dbhelper.DBhelper) error {
 	return err
 }
 
+//LoadEventTypes loads the event types of all stored webhooks of the source
+func (source *Source) LoadEventTypes(db *dbhelper.DBhelper) error {
+	return db.QueryRowsf(&source.EventTypes, "SELECT DISTINCT eventType FROM %s WHERE sourceID=? AND eventType != ''", []string{TableWebhooks}, source.PkID)
+}
+
+//AddDuplicate increases the count of duplicate webhooks the source received
+func (source *Source) AddDuplicate(db *dbhelper.DBhelper) error {
+	_, err := db.Execf("UPDATE %s SET duplicates=duplicates+1 WHERE pk_id=?", []string{TableSources}, source.PkID)
+	return err
+}
+
+//RotateSecret creates a new secret. The old secret stays valid for the given grace period
+func (source *Source) RotateSecret(db *dbhelper.DBhelper, gracePeriod time.Duration) error {
+	newSecret := gaw.RandString(48)
+	expires := time.Now().Add(gracePeriod)
+
+	_, err := db.Execf("UPDATE %s SET secret=?, oldSecret=?, oldSecretExpires=FROM_UNIXTIME(?) WHERE pk_id=?", []string{TableSources}, newSecret, source.Secret, expires.Unix(), source.PkID)
+	if err != nil {
+		return err
+	}
+
+	source.OldSecret = source.Secret
+	source.OldExpires = &expires
+	source.Secret = newSecret
+	return nil
+}
+
+//GetValidSecrets returns all secrets which are currently accepted for the source
+func (source Source) GetValidSecrets() []string {
+	secrets := []string{source.Secret}
+
+	//The old secret is valid until the grace period is over
+	if len(source.OldSecret) > 0 && source.OldExpires != nil && source.OldExpires.After(time.Now()) {
+		secrets = append(secrets, source.OldSecret)
+	}
+
+	return secrets
+}
+
+//DeleteExpiredSecrets removes old secrets which are expired
+func DeleteExpiredSecrets(db *dbhelper.DBhelper) error {
+	_, err := db.Execf("UPDATE %s SET oldSecret='', oldSecretExpires=NULL WHERE oldSecretExpires <= now()", []string{TableSources})
+	return err
+}
+
+//IsSync returns true if webhooks are relayed synchronously to a primary subscription
+func (source Source) IsSync() bool {
+	return source.SyncSubsPK > 0
+}
+
+//GetSyncSubscription returns the primary subscription of a synchronous source
+func (source Source) GetSyncSubscription(db *dbhelper.DBhelper) (*Subscription, error) {
+	return GetSubscriptionByPK(db, source.SyncSubsPK)
+}
+
+//SetHashDeduplication sets whether webhooks without a delivery ID get deduplicated by their content
+func (source *Source) SetHashDeduplication(db *dbhelper.DBhelper, enabled bool) error {
+	_, err := db.Execf("UPDATE %s SET hashDeduplication=? WHERE pk_id=?", []string{TableSources}, enabled, source.PkID)
+	if err != nil {
+		return err
+	}
+
+	source.HashDeduplication = enabled
+	return nil
+}
+
+//UpdateSyncSubscription sets the primary subscription. 0 makes the source asynchronous
+func (source *Source) UpdateSyncSubscription(db *dbhelper.DBhelper, subscriptionPK uint32) error {
+	_, err := db.Execf("UPDATE %s SET syncSubscription=? WHERE pk_id=?", []string{TableSources}, subscriptionPK, source.PkID)
+	if err != nil {
+		return err
+	}
+
+	source.SyncSubsPK = subscriptionPK
+	return nil
+}
+
+//SetPaused pauses or resumes the delivery of webhooks. Paused sources still accept and store webhooks
+func (source *Source) SetPaused(db *dbhelper.DBhelper, paused bool) error {
+	_, err := db.Execf("UPDATE %s SET paused=? WHERE pk_id=?", []string{TableSources}, paused, source.PkID)
+	if err != nil {
+		return err
+	}
+
+	source.IsPaused = paused
+	return nil
+}
+
+//IsSourcePaused returns true if the source with the given pk_id is paused
+func IsSourcePaused(db *dbhelper.DBhelper, pkID uint32) (bool, error) {
+	var paused bool
+	err := db.QueryRowf(&paused, "SELECT paused FROM %s WHERE pk_id=?", []string{TableSources}, pkID)
+	return paused, err
+}
+
 //Update source
 func (source *Source) Update(db *dbhelper.DBhelper, field, newText string, arg ...bool) error {
 	if newText == "-" && len(arg) > 0 {
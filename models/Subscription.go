@@ -1,7 +1,8 @@
 package models
 
 import (
-	"math/rand"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"strings"
 	"time"
@@ -9,6 +10,7 @@ import (
 	gaw "github.com/JojiiOfficial/GoAw"
 	dbhelper "github.com/JojiiOfficial/GoDBHelper"
 	"github.com/JojiiOfficial/WhShareServer/constants"
+	"github.com/JojiiOfficial/WhShareServer/modes"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -22,6 +24,25 @@ type Subscription struct {
 	Time           time.Time `db:"time"`
 	IsValid        bool      `db:"isValid"`
 	LastTrigger    string    `db:"lastTrigger"`
+	Template       string    `db:"template"`
+	TemplateType   string    `db:"templateType"`
+	Filter         string    `db:"filter"`
+	EventTypes     string    `db:"eventTypes"`
+
+	SigningSecret     string     `db:"signingSecret"`
+	OldSigningSecret  string     `db:"oldSigningSecret"`
+	OldSigningExpires *time.Time `db:"oldSigningSecretExpires" orm:"-"`
+
+	Method   string `db:"method"`
+	Headers  string `db:"headers"`
+	Auth     string `db:"auth"`
+	AuthType string `db:"authType"`
+
+	Timeout     uint32 `db:"timeout"`
+	MaxAttempts uint8  `db:"maxAttempts"`
+	Backoff     string `db:"backoff"`
+
+	Ordered bool `db:"ordered"`
 }
 
 //TableSubscriptions the tableName for subscriptions
@@ -30,77 +51,176 @@ const (
 	TableModes         = "Modes"
 )
 
-//NotifyAllSubscriber for a given webhook
-func NotifyAllSubscriber(db *dbhelper.DBhelper, config *ConfigStruct, webhook *Webhook, source *Source, callback NotifyCallback) {
+//NotifyAllSubscriber schedules the delivery of a webhook to all subscriptions which want to receive it.
+//Returns the count of scheduled deliveries or ErrQueueFull if the webhook has to be scheduled again later
+func NotifyAllSubscriber(db *dbhelper.DBhelper, scheduler DeliveryScheduler, webhook *Webhook, source *Source) (int, error) {
 	subscriptions, err := source.getSubscriptions(db)
 	if LogError(err) {
-		return
+		return 0, err
 	}
 
-	if len(subscriptions) > 0 {
-		log.Debugf("Starting pool for %d subscriber\n", len(subscriptions))
+	//Skip subscriptions which don't want this webhook
+	subscriptions = webhook.filterSubscriptions(subscriptions)
 
-		go (func() {
-			startPool(db, config, webhook, source, subscriptions, callback)
-		})()
-	} else {
+	if len(subscriptions) == 0 {
 		log.Info("No subscriber found!")
+		return 0, nil
 	}
-}
 
-//Start notifier pool
-func startPool(db *dbhelper.DBhelper, config *ConfigStruct, webhook *Webhook, source *Source, subscriptions []Subscription, callback NotifyCallback) {
-	pos := 0
+	deliveries := make([]Delivery, len(subscriptions))
+	for i := range subscriptions {
+		deliveries[i] = Delivery{
+			Webhook:      webhook,
+			Source:       source,
+			Subscription: &subscriptions[i],
+		}
 
-	c := make(chan int, 1)
-	c <- config.Server.WorkerCount
+		//Ordered subscriptions get the next webhook they didn't receive yet
+		if subscriptions[i].Ordered {
+			deliveries[i].Ordered = true
+			if !webhook.IsReplay {
+				if err = subscriptions[i].enqueueOrdered(db, webhook.PkID); err != nil {
+					return 0, err
+				}
+				deliveries[i].Webhook = nil
+			}
+		}
+	}
 
-	for pos < len(subscriptions) {
-		read := <-c
-		for i := 0; i < read && pos < len(subscriptions); i++ {
+	log.Debugf("Scheduling %d deliveries\n", len(deliveries))
+	if err = scheduler.Schedule(deliveries...); err != nil {
+		return 0, err
+	}
 
-			go (func(c *chan int, subscription *Subscription, webhook *Webhook, source *Source) {
-				rand.Seed(time.Now().UnixNano())
-				<-time.After(time.Duration(rand.Intn(999)+1) * time.Millisecond)
-				subscription.Notify(db, webhook, source, callback)
-				*c <- 1
-			})(&c, &subscriptions[pos], webhook, source)
+	return len(deliveries), nil
+}
 
-			pos++
-		}
-	}
+//DeliveryResponse the response of a subscriber
+type DeliveryResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
 }
 
+//IsSuccess returns true if the subscriber accepted the delivery
+func (response DeliveryResponse) IsSuccess() bool {
+	return response.StatusCode >= 200 && response.StatusCode <= 299
+}
+
+//Max size of a subscribers response body which gets read
+const maxDeliveryResponseSize = 1 << 20
+
 //Notify subscriber
-func (subscription *Subscription) Notify(db *dbhelper.DBhelper, webhook *Webhook, source *Source, callback NotifyCallback) (*http.Response, error) {
+func (subscription *Subscription) Notify(db *dbhelper.DBhelper, webhook *Webhook, source *Source, callback NotifyCallback) (*DeliveryResponse, error) {
+	return subscription.NotifyWithTimeout(db, webhook, source, callback, subscription.GetTimeout())
+}
+
+//NotifyWithTimeout notifies the subscriber and returns its response
+func (subscription *Subscription) NotifyWithTimeout(db *dbhelper.DBhelper, webhook *Webhook, source *Source, callback NotifyCallback, timeout time.Duration) (*DeliveryResponse, error) {
 	client := &http.Client{
-		Timeout: 20 * time.Second,
+		Timeout: timeout,
 	}
-	req, _ := http.NewRequest("POST", subscription.CallbackURL, strings.NewReader(webhook.Payload))
-
 	//Load headers from webhook.Headers
-	setHeadersFromStr(webhook.Headers, &req.Header)
+	header := webhook.GetHeader()
+	RemoveHopByHopHeaders(header)
+
+	//Webhooks stored before secret headers were removed on receipt might still contain them
+	if mode, has := modes.Get(source.Mode); has {
+		for _, secretHeader := range mode.SecretHeaders() {
+			header.Del(secretHeader)
+		}
+	}
+
+	//Apply the current redaction rules. They might have changed since the webhook was stored
+	redacted, err := source.Redact(header, []byte(webhook.Payload))
+	if err != nil {
+		LogError(err, log.Fields{"msg": "Error redacting webhook", "source": source.SourceID})
+		recordDeliveryAttempt(db, webhook, subscription, nil, 0, err)
+		callback.OnError(*subscription, *source, *webhook)
+		return nil, err
+	}
+	payload := string(redacted)
+
+	//Reshape the payload using the subscriptions template
+	if subscription.HasTemplate() {
+		redactedHook := *webhook
+		redactedHook.Payload = payload
+		payload, err = subscription.renderPayload(&redactedHook, source, header)
+		if err != nil {
+			LogError(err, log.Fields{"msg": "Error rendering template", "subscription": subscription.SubscriptionID})
+			recordDeliveryAttempt(db, webhook, subscription, nil, 0, err)
+			callback.OnError(*subscription, *source, *webhook)
+			return nil, err
+		}
+
+		if len(subscription.TemplateType) > 0 {
+			header.Set("Content-Type", subscription.TemplateType)
+		}
+	}
+
+	req, err := http.NewRequest(subscription.getDeliveryMethod(webhook), subscription.CallbackURL, strings.NewReader(payload))
+	if err != nil {
+		LogError(err, log.Fields{"msg": "Error creating request", "subscription": subscription.SubscriptionID})
+		recordDeliveryAttempt(db, webhook, subscription, nil, 0, err)
+		callback.OnError(*subscription, *source, *webhook)
+		return nil, err
+	}
+	req.Header = header
+
+	//Keep the query of the original request
+	if len(webhook.Query) > 0 {
+		if len(req.URL.RawQuery) > 0 {
+			req.URL.RawQuery += "&" + webhook.Query
+		} else {
+			req.URL.RawQuery = webhook.Query
+		}
+	}
 
 	//Add header for client
 	req.Header.Set(constants.HeaderReceived, webhook.Received.Format(time.Stamp))
 	req.Header.Set(constants.HeaderSource, source.SourceID)
 	req.Header.Set(constants.HeaderSubsID, subscription.SubscriptionID)
+	if webhook.IsReplay {
+		req.Header.Set(constants.HeaderReplay, "1")
+	}
+
+	//Add static headers and credentials of the subscription
+	if err = subscription.applyRequestOptions(req); err != nil {
+		LogError(err, log.Fields{"msg": "Error decrypting credentials", "subscription": subscription.SubscriptionID})
+		recordDeliveryAttempt(db, webhook, subscription, nil, 0, err)
+		callback.OnError(*subscription, *source, *webhook)
+		return nil, err
+	}
+
+	//Sign the delivery so the subscriber can verify its origin
+	subscription.signDelivery(req.Header, []byte(payload), time.Now())
 
 	//Do the request
+	var response *DeliveryResponse
+	start := time.Now()
 	resp, err := client.Do(req)
+	if err == nil {
+		response = &DeliveryResponse{
+			StatusCode: resp.StatusCode,
+			Header:     resp.Header,
+		}
+		response.Body, err = ioutil.ReadAll(io.LimitReader(resp.Body, maxDeliveryResponseSize))
+		resp.Body.Close()
+	}
 	LogError(err)
+	recordDeliveryAttempt(db, webhook, subscription, response, time.Since(start), err)
 
-	if err != nil || resp.StatusCode > 299 || resp.StatusCode < 200 {
+	if err != nil || !response.IsSuccess() {
 		callback.OnError(*subscription, *source, *webhook)
-	} else if resp.StatusCode == http.StatusTeapot {
+	} else if response.StatusCode == http.StatusTeapot {
 		//Unsubscribe
 		callback.OnUnsubscribe(*subscription)
 	} else {
 		//Successful notification
-		callback.OnSuccess(*subscription)
+		callback.OnSuccess(*subscription, *webhook)
 	}
 
-	return resp, err
+	return response, err
 }
 
 // ------------------------ Queries
@@ -154,10 +274,53 @@ func (subscription *Subscription) UpdateCallback(db *dbhelper.DBhelper, newCallb
 	return err
 }
 
+//UpdateTemplate updates the payload template of the subscription
+func (subscription *Subscription) UpdateTemplate(db *dbhelper.DBhelper, template, contentType string) error {
+	_, err := db.Execf("UPDATE %s SET template=?, templateType=? WHERE pk_id=?", []string{TableSubscriptions}, template, contentType, subscription.PkID)
+	return err
+}
+
+//UpdateFilter updates the delivery filter of the subscription
+func (subscription *Subscription) UpdateFilter(db *dbhelper.DBhelper, filter string) error {
+	_, err := db.Execf("UPDATE %s SET filter=? WHERE pk_id=?", []string{TableSubscriptions}, filter, subscription.PkID)
+	return err
+}
+
+//UpdateEventTypes updates the event types the subscription accepts
+func (subscription *Subscription) UpdateEventTypes(db *dbhelper.DBhelper, eventTypes []string) error {
+	subscription.EventTypes = strings.Join(eventTypes, ",")
+	_, err := db.Execf("UPDATE %s SET eventTypes=? WHERE pk_id=?", []string{TableSubscriptions}, subscription.EventTypes, subscription.PkID)
+	return err
+}
+
+//GetEventTypes returns the event types the subscription accepts. Empty if all are accepted
+func (subscription Subscription) GetEventTypes() []string {
+	if len(subscription.EventTypes) == 0 {
+		return []string{}
+	}
+	return strings.Split(subscription.EventTypes, ",")
+}
+
+//AcceptsEventType return true if the subscription wants webhooks of the event type
+func (subscription Subscription) AcceptsEventType(eventType string) bool {
+	eventTypes := subscription.GetEventTypes()
+	if len(eventTypes) == 0 {
+		return true
+	}
+
+	return gaw.IsInStringArray(eventType, eventTypes)
+}
+
 //Insert inserts the subscription into the db
 func (subscription *Subscription) Insert(db *dbhelper.DBhelper) error {
+	secret, err := NewSigningSecret()
+	if err != nil {
+		return err
+	}
+
 	subscription.SubscriptionID = gaw.RandString(32)
-	_, err := db.Insert(subscription, &dbhelper.InsertOption{
+	subscription.SigningSecret = secret
+	_, err = db.Insert(subscription, &dbhelper.InsertOption{
 		TableName: TableSubscriptions,
 		SetPK:     true,
 	})
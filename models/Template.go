@@ -0,0 +1,100 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"text/template"
+	"time"
+
+	"github.com/JojiiOfficial/WhShareServer/modes"
+)
+
+//ErrTemplateTooLong error if a template exceeds the max length
+var ErrTemplateTooLong = errors.New("template too long")
+
+//TemplateData the data passed to a payload template
+type TemplateData struct {
+	Payload  interface{}
+	Raw      string
+	Headers  map[string]string
+	Source   TemplateSource
+	Received time.Time
+}
+
+//TemplateSource source metadata passed to a payload template
+type TemplateSource struct {
+	ID          string
+	Name        string
+	Description string
+	Mode        string
+}
+
+//Functions available in payload templates
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+//ParsePayloadTemplate parses a payload template
+func ParsePayloadTemplate(tmpl string) (*template.Template, error) {
+	return template.New("payload").Funcs(templateFuncs).Option("missingkey=zero").Parse(tmpl)
+}
+
+//ValidatePayloadTemplate returns an error if the template or its content type is invalid
+func ValidatePayloadTemplate(tmpl, contentType string, maxLength int) error {
+	if len(tmpl) > maxLength {
+		return ErrTemplateTooLong
+	}
+
+	if len(contentType) > 0 {
+		if _, _, err := mime.ParseMediaType(contentType); err != nil {
+			return err
+		}
+	}
+
+	_, err := ParsePayloadTemplate(tmpl)
+	return err
+}
+
+//HasTemplate return true if the subscription has a payload template
+func (subscription Subscription) HasTemplate() bool {
+	return len(subscription.Template) > 0
+}
+
+//renderPayload executes the payload template of the subscription for a webhook
+func (subscription Subscription) renderPayload(webhook *Webhook, source *Source, header http.Header) (string, error) {
+	tmpl, err := ParsePayloadTemplate(subscription.Template)
+	if err != nil {
+		return "", err
+	}
+
+	headers := make(map[string]string)
+	for key := range header {
+		headers[key] = header.Get(key)
+	}
+
+	data := TemplateData{
+		Payload:  ParsePayload(header.Get("Content-Type"), []byte(webhook.Payload)),
+		Raw:      webhook.Payload,
+		Headers:  headers,
+		Received: webhook.Received,
+		Source: TemplateSource{
+			ID:          source.SourceID,
+			Name:        source.Name,
+			Description: source.Description,
+			Mode:        modes.NameOf(source.Mode),
+		},
+	}
+
+	var buff bytes.Buffer
+	if err = tmpl.Execute(&buff, data); err != nil {
+		return "", err
+	}
+
+	return buff.String(), nil
+}
@@ -1,18 +1,33 @@
 package models
 
 import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
 	"time"
 
 	dbhelper "github.com/JojiiOfficial/GoDBHelper"
+	"github.com/jmoiron/sqlx"
+	log "github.com/sirupsen/logrus"
 )
 
 //Webhook the actual webhook from a server
 type Webhook struct {
-	PkID     uint32    `db:"pk_id" orm:"pk,ai"`
-	SourceID uint32    `db:"sourceID"`
-	Headers  string    `db:"header"`
-	Payload  string    `db:"payload"`
-	Received time.Time `db:"received"`
+	PkID       uint32    `db:"pk_id" orm:"pk,ai"`
+	SourceID   uint32    `db:"sourceID"`
+	Headers    string    `db:"header"`
+	Method     string    `db:"method"`
+	Query      string    `db:"query"`
+	Payload    string    `db:"payload"`
+	Received   time.Time `db:"received"`
+	EventType  string    `db:"eventType"`
+	DeliveryID string    `db:"deliveryID"`
+
+	//ExcludedSubscription a subscription which already got the webhook
+	ExcludedSubscription uint32 `db:"-" orm:"-"`
+	//IsReplay true if the webhook is sent again
+	IsReplay bool `db:"-" orm:"-"`
 }
 
 //TableWebhooks table for the webhooks
@@ -28,6 +43,43 @@ func GetWebhookByPK(db *dbhelper.DBhelper, webhookID uint32) (*Webhook, error) {
 	return &webhook, nil
 }
 
+//TableDeliveryIDs table containing the delivery IDs of the received webhooks
+const TableDeliveryIDs = "DeliveryIDs"
+
+//ErrDuplicateWebhook error if the source received a webhook with the same delivery ID within the deduplication window
+var ErrDuplicateWebhook = errors.New("duplicate webhook")
+
+//claimDeliveryID stores the delivery ID of the webhook. Returns ErrDuplicateWebhook if it was
+//stored within the given window. The unique key lets only one of concurrent webhooks claim an ID
+func (webhook Webhook) claimDeliveryID(tx *sqlx.Tx, window time.Duration) error {
+	res, err := tx.Exec(fmt.Sprintf("INSERT IGNORE INTO %s (sourceID, deliveryID) VALUES (?,?)", TableDeliveryIDs), webhook.SourceID, webhook.DeliveryID)
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil || n > 0 {
+		return err
+	}
+
+	//The ID is known already. Claim it again if it's older than the window
+	res, err = tx.Exec(fmt.Sprintf("UPDATE %s SET received=CURRENT_TIMESTAMP WHERE sourceID=? AND deliveryID=? AND received < FROM_UNIXTIME(?)", TableDeliveryIDs), webhook.SourceID, webhook.DeliveryID, time.Now().Add(-window).Unix())
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err == nil && n == 0 {
+		return ErrDuplicateWebhook
+	}
+	return err
+}
+
+//DeleteOldDeliveryIDs deletes delivery IDs which were received before the given time
+func DeleteOldDeliveryIDs(db *dbhelper.DBhelper, before time.Time) error {
+	_, err := db.Execf("DELETE FROM %s WHERE received < FROM_UNIXTIME(?)", []string{TableDeliveryIDs}, before.Unix())
+	return err
+}
+
 //Insert webhook
 func (webhook *Webhook) Insert(db *dbhelper.DBhelper) error {
 	_, err := db.Insert(webhook, &dbhelper.InsertOption{
@@ -36,3 +88,126 @@ func (webhook *Webhook) Insert(db *dbhelper.DBhelper) error {
 	})
 	return err
 }
+
+//GetWebhooksSince returns the oldest webhooks of a source received since the given time
+func GetWebhooksSince(db *dbhelper.DBhelper, sourcePK uint32, since time.Time, limit int) ([]Webhook, error) {
+	var webhooks []Webhook
+	err := db.QueryRowsf(&webhooks, "SELECT * FROM %s WHERE sourceID=? AND received >= FROM_UNIXTIME(?) ORDER BY pk_id ASC LIMIT ?", []string{TableWebhooks}, sourcePK, since.Unix(), limit)
+	return webhooks, err
+}
+
+//WebhookFilter filters webhooks of a source
+type WebhookFilter struct {
+	Cursor     uint32
+	Limit      int
+	From       *time.Time
+	To         *time.Time
+	EventTypes []string
+}
+
+//GetWebhooksOfSource returns the webhooks of a source matching the filter from new to old
+func GetWebhooksOfSource(db *dbhelper.DBhelper, sourcePK uint32, filter WebhookFilter) ([]Webhook, error) {
+	query := "SELECT * FROM %s WHERE sourceID=?"
+	args := []interface{}{sourcePK}
+
+	if filter.Cursor > 0 {
+		query += " AND pk_id < ?"
+		args = append(args, filter.Cursor)
+	}
+	if filter.From != nil {
+		query += " AND received >= FROM_UNIXTIME(?)"
+		args = append(args, filter.From.Unix())
+	}
+	if filter.To != nil {
+		query += " AND received <= FROM_UNIXTIME(?)"
+		args = append(args, filter.To.Unix())
+	}
+	if len(filter.EventTypes) > 0 {
+		query += " AND eventType IN (?" + strings.Repeat(",?", len(filter.EventTypes)-1) + ")"
+		for _, eventType := range filter.EventTypes {
+			args = append(args, eventType)
+		}
+	}
+
+	query += " ORDER BY pk_id DESC LIMIT ?"
+	args = append(args, filter.Limit)
+
+	var webhooks []Webhook
+	err := db.QueryRowsf(&webhooks, query, []string{TableWebhooks}, args...)
+	return webhooks, err
+}
+
+//IsAcceptedBy returns true if the subscription accepts the webhook
+func (webhook *Webhook) IsAcceptedBy(subscription Subscription) bool {
+	return len(webhook.filterSubscriptions([]Subscription{subscription})) == 1
+}
+
+//NormalizeEventType returns an event type in lowercase with words separated by _
+func NormalizeEventType(eventType string) string {
+	eventType = strings.ToLower(strings.TrimSpace(eventType))
+	eventType = strings.Join(strings.FieldsFunc(eventType, func(r rune) bool {
+		return r == ' ' || r == '-' || r == '_'
+	}), "_")
+
+	if len(eventType) > 64 {
+		eventType = eventType[:64]
+	}
+
+	return eventType
+}
+
+//filterSubscriptions returns all subscriptions which accept the webhook
+func (webhook *Webhook) filterSubscriptions(subscriptions []Subscription) []Subscription {
+	var accepted []Subscription
+	var header http.Header
+	var payload interface{}
+
+	for _, subscription := range subscriptions {
+		if subscription.PkID == webhook.ExcludedSubscription {
+			log.Debugf("Skipping subscription %s. Reason: already notified\n", subscription.SubscriptionID)
+			continue
+		}
+
+		if !subscription.AcceptsEventType(webhook.EventType) {
+			log.Debugf("Skipping subscription %s. Reason: event type '%s' not subscribed\n", subscription.SubscriptionID, webhook.EventType)
+			continue
+		}
+
+		if len(subscription.Filter) == 0 {
+			accepted = append(accepted, subscription)
+			continue
+		}
+
+		filter, err := ParseFilter(subscription.Filter)
+		if err != nil {
+			LogError(err, log.Fields{"msg": "Invalid filter", "subscription": subscription.SubscriptionID})
+			continue
+		}
+
+		//Parse the webhook only if required
+		if header == nil {
+			header = webhook.GetHeader()
+			payload = ParsePayload(header.Get("Content-Type"), []byte(webhook.Payload))
+		}
+
+		if filter.Matches(payload, header) {
+			accepted = append(accepted, subscription)
+		} else {
+			log.Debugf("Skipping subscription %s. Reason: filter doesn't match\n", subscription.SubscriptionID)
+		}
+	}
+
+	return accepted
+}
+
+//GetInfo returns a summary of the webhook
+func (webhook Webhook) GetInfo() WebhookInfo {
+	return WebhookInfo{
+		ID:          webhook.PkID,
+		Received:    webhook.Received,
+		EventType:   webhook.EventType,
+		DeliveryID:  webhook.DeliveryID,
+		Method:      webhook.GetMethod(),
+		PayloadSize: len(webhook.Payload),
+	}
+}
@@ -5,15 +5,17 @@ import (
 	"strings"
 )
 
-func setHeadersFromStr(headers string, header *http.Header) {
-	headersrn := strings.Split(headers, "\r\n")
-	for _, v := range headersrn {
-		if !strings.Contains(v, "=") {
+//parseLegacyHeader parses headers stored as key=value pairs separated by \r\n.
+//Multiple values were joined by ; and can't be split again reliably
+func parseLegacyHeader(headers string) http.Header {
+	header := http.Header{}
+	for _, v := range strings.Split(headers, "\r\n") {
+		kp := strings.SplitN(v, "=", 2)
+		if len(kp) != 2 {
 			continue
 		}
-		kp := strings.Split(v, "=")
-		key := kp[0]
 
-		(*header).Set(key, kp[1])
+		header.Add(kp[0], kp[1])
 	}
+	return header
 }
@@ -0,0 +1,53 @@
+package modes
+
+import (
+	"net/http"
+)
+
+const (
+	//headerBitbucketSignature HMAC-SHA256 signature of the payload sent by bitbucket
+	headerBitbucketSignature = "X-Hub-Signature"
+	//headerBitbucketEvent the event key sent by bitbucket. Eg. repo:push
+	headerBitbucketEvent = "X-Event-Key"
+	//headerBitbucketDelivery the request ID sent by bitbucket
+	headerBitbucketDelivery = "X-Request-UUID"
+)
+
+type bitbucketMode struct {
+	baseMode
+}
+
+func (bitbucketMode) ID() uint8 {
+	return 5
+}
+
+func (bitbucketMode) Name() string {
+	return "bitbucket"
+}
+
+func (bitbucketMode) EventType(header http.Header, payload []byte) string {
+	return header.Get(headerBitbucketEvent)
+}
+
+func (bitbucketMode) VerifySignature(header http.Header, payload []byte, secrets []string) error {
+	return verifySHA256(header.Get(headerBitbucketSignature), "sha256=", secrets, payload)
+}
+
+func (bitbucketMode) DeliveryID(header http.Header) string {
+	return header.Get(headerBitbucketDelivery)
+}
+
+//Bitbucket sends a ping to test the connection
+func (bitbucketMode) Handshake(header http.Header, payload []byte) *HandshakeResponse {
+	if header.Get(headerBitbucketEvent) != "diagnostics:ping" {
+		return nil
+	}
+
+	return &HandshakeResponse{
+		StatusCode: http.StatusOK,
+	}
+}
+
+func (bitbucketMode) SecretHeaders() []string {
+	return []string{headerBitbucketSignature}
+}
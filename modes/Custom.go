@@ -0,0 +1,36 @@
+package modes
+
+import (
+	"net/http"
+)
+
+const (
+	//headerCustomSignature HMAC-SHA256 signature of the payload for custom sources
+	headerCustomSignature = "X-Webhook-Signature"
+	//headerCustomEvent the event type for custom sources
+	headerCustomEvent = "X-Event-Type"
+)
+
+type customMode struct {
+	baseMode
+}
+
+func (customMode) ID() uint8 {
+	return 0
+}
+
+func (customMode) Name() string {
+	return "custom"
+}
+
+func (customMode) EventType(header http.Header, payload []byte) string {
+	return header.Get(headerCustomEvent)
+}
+
+func (customMode) VerifySignature(header http.Header, payload []byte, secrets []string) error {
+	return verifySHA256(header.Get(headerCustomSignature), "sha256=", secrets, payload)
+}
+
+func (customMode) SecretHeaders() []string {
+	return []string{headerCustomSignature}
+}
@@ -0,0 +1,223 @@
+package modes
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+//Definition a mode declared in the config file
+type Definition struct {
+	//ID the ID of the mode used in sources. Must not collide with other modes
+	ID   uint8
+	Name string
+
+	//EventHeader header containing the event type
+	EventHeader string
+	//EventPath JSON path of the event type in the payload. Eg. $.event.type
+	EventPath string
+
+	//HMACAlgorithm sha1, sha256 or sha512. 'token' compares the header with the secret
+	HMACAlgorithm string
+	//SignatureHeader header containing the signature
+	SignatureHeader string
+	//SignaturePrefix prefix of the signature. Eg. sha256=
+	SignaturePrefix string
+	//SignatureEncoding hex (default) or base64
+	SignatureEncoding string
+
+	//DeliveryHeader header containing the unique ID of a delivery
+	DeliveryHeader string
+	//StripPaths JSON objects which are always removed from payloads
+	StripPaths []string
+}
+
+//Supported HMAC algorithms
+var hmacAlgorithms = map[string]func() hash.Hash{
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+var modeNameRegex = regexp.MustCompile("^[a-z0-9_-]+$")
+
+type declarativeMode struct {
+	definition Definition
+	hashFunc   func() hash.Hash
+	eventPath  []string
+}
+
+//NewDeclarativeMode creates a mode from a definition
+func NewDeclarativeMode(definition Definition) (Mode, error) {
+	definition.HMACAlgorithm = strings.ToLower(definition.HMACAlgorithm)
+	mode := declarativeMode{
+		definition: definition,
+	}
+
+	if !modeNameRegex.MatchString(definition.Name) {
+		return nil, fmt.Errorf("invalid mode name '%s'. Use lowercase letters, digits, - and _", definition.Name)
+	}
+
+	switch definition.HMACAlgorithm {
+	case "", "token":
+	default:
+		hashFunc, has := hmacAlgorithms[definition.HMACAlgorithm]
+		if !has {
+			return nil, fmt.Errorf("mode '%s': unsupported HMAC algorithm '%s'", definition.Name, definition.HMACAlgorithm)
+		}
+		mode.hashFunc = hashFunc
+	}
+
+	if len(definition.HMACAlgorithm) > 0 && len(definition.SignatureHeader) == 0 {
+		return nil, fmt.Errorf("mode '%s': missing SignatureHeader", definition.Name)
+	}
+
+	switch definition.SignatureEncoding {
+	case "", "hex", "base64":
+	default:
+		return nil, fmt.Errorf("mode '%s': unsupported signature encoding '%s'", definition.Name, definition.SignatureEncoding)
+	}
+
+	if len(definition.EventPath) > 0 {
+		path := strings.TrimPrefix(strings.TrimSpace(definition.EventPath), "$.")
+		for _, segment := range strings.Split(path, ".") {
+			if len(segment) == 0 {
+				return nil, fmt.Errorf("mode '%s': invalid EventPath '%s'", definition.Name, definition.EventPath)
+			}
+			mode.eventPath = append(mode.eventPath, segment)
+		}
+	}
+
+	return mode, nil
+}
+
+//RegisterDefinitions creates and registers modes from definitions
+func RegisterDefinitions(definitions []Definition) error {
+	for _, definition := range definitions {
+		mode, err := NewDeclarativeMode(definition)
+		if err != nil {
+			return err
+		}
+
+		if err = Register(mode); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (mode declarativeMode) ID() uint8 {
+	return mode.definition.ID
+}
+
+func (mode declarativeMode) Name() string {
+	return mode.definition.Name
+}
+
+func (mode declarativeMode) EventType(header http.Header, payload []byte) string {
+	if len(mode.definition.EventHeader) > 0 {
+		if event := header.Get(mode.definition.EventHeader); len(event) > 0 {
+			return event
+		}
+	}
+
+	if len(mode.eventPath) > 0 {
+		return lookupJSONString(payload, mode.eventPath)
+	}
+
+	return ""
+}
+
+func (mode declarativeMode) VerifySignature(header http.Header, payload []byte, secrets []string) error {
+	if len(mode.definition.HMACAlgorithm) == 0 {
+		//Without signature the secret has to be passed in the URL
+		return ErrMissingSignature
+	}
+
+	signature := strings.TrimPrefix(strings.TrimSpace(header.Get(mode.definition.SignatureHeader)), mode.definition.SignaturePrefix)
+	if mode.definition.HMACAlgorithm == "token" {
+		return verifyToken(signature, secrets)
+	}
+
+	if len(signature) == 0 {
+		return ErrMissingSignature
+	}
+
+	var sig []byte
+	var err error
+	if mode.definition.SignatureEncoding == "base64" {
+		sig, err = base64.StdEncoding.DecodeString(signature)
+	} else {
+		sig, err = hex.DecodeString(signature)
+	}
+
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	return verifyHMAC(mode.hashFunc, sig, secrets, payload)
+}
+
+func (mode declarativeMode) SecretHeaders() []string {
+	if len(mode.definition.SignatureHeader) == 0 {
+		return nil
+	}
+	return []string{mode.definition.SignatureHeader}
+}
+
+func (mode declarativeMode) DeliveryID(header http.Header) string {
+	if len(mode.definition.DeliveryHeader) == 0 {
+		return ""
+	}
+	return header.Get(mode.definition.DeliveryHeader)
+}
+
+func (mode declarativeMode) DefaultFilters() []string {
+	return mode.definition.StripPaths
+}
+
+func (mode declarativeMode) Handshake(header http.Header, payload []byte) *HandshakeResponse {
+	return nil
+}
+
+//lookupJSONString returns the value at the path of a JSON payload as string
+func lookupJSONString(payload []byte, path []string) string {
+	var current interface{}
+	if json.Unmarshal(payload, &current) != nil {
+		return ""
+	}
+
+	for _, segment := range path {
+		switch v := current.(type) {
+		case map[string]interface{}:
+			current = v[segment]
+		case []interface{}:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(v) {
+				return ""
+			}
+			current = v[index]
+		default:
+			return ""
+		}
+	}
+
+	switch v := current.(type) {
+	case string:
+		return v
+	case float64, bool:
+		return fmt.Sprint(v)
+	}
+
+	return ""
+}
@@ -0,0 +1,41 @@
+package modes
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+type dockerMode struct {
+	baseMode
+}
+
+func (dockerMode) ID() uint8 {
+	return 2
+}
+
+func (dockerMode) Name() string {
+	return "docker"
+}
+
+//Docker hub doesn't send an event header. Its only event are pushes
+func (dockerMode) EventType(header http.Header, payload []byte) string {
+	var dockerPayload struct {
+		PushData json.RawMessage `json:"push_data"`
+	}
+
+	if json.Unmarshal(payload, &dockerPayload) == nil && len(dockerPayload.PushData) > 0 {
+		return "push"
+	}
+
+	return ""
+}
+
+//Docker hub doesn't sign webhooks. The secret has to be passed in the URL
+func (dockerMode) VerifySignature(header http.Header, payload []byte, secrets []string) error {
+	return ErrMissingSignature
+}
+
+//The callback_url allows everyone to set the status of the build
+func (dockerMode) DefaultFilters() []string {
+	return []string{"callback_url"}
+}
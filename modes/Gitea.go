@@ -0,0 +1,43 @@
+package modes
+
+import (
+	"net/http"
+)
+
+const (
+	//headerGiteaSignature HMAC-SHA256 signature of the payload sent by gitea
+	headerGiteaSignature = "X-Gitea-Signature"
+	//headerGiteaEvent the event type sent by gitea
+	headerGiteaEvent = "X-Gitea-Event"
+	//headerGiteaDelivery the delivery ID sent by gitea
+	headerGiteaDelivery = "X-Gitea-Delivery"
+)
+
+type giteaMode struct {
+	baseMode
+}
+
+func (giteaMode) ID() uint8 {
+	return 4
+}
+
+func (giteaMode) Name() string {
+	return "gitea"
+}
+
+func (giteaMode) EventType(header http.Header, payload []byte) string {
+	return header.Get(headerGiteaEvent)
+}
+
+//Gitea sends the signature without prefix
+func (giteaMode) VerifySignature(header http.Header, payload []byte, secrets []string) error {
+	return verifySHA256(header.Get(headerGiteaSignature), "", secrets, payload)
+}
+
+func (giteaMode) DeliveryID(header http.Header) string {
+	return header.Get(headerGiteaDelivery)
+}
+
+func (giteaMode) SecretHeaders() []string {
+	return []string{headerGiteaSignature}
+}
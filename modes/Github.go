@@ -0,0 +1,57 @@
+package modes
+
+import (
+	"net/http"
+)
+
+const (
+	//headerGithubSignature HMAC-SHA256 signature of the payload sent by github
+	headerGithubSignature = "X-Hub-Signature-256"
+	//headerGithubLegacySignature HMAC-SHA1 signature of the payload sent by github
+	headerGithubLegacySignature = "X-Hub-Signature"
+	//headerGithubEvent the event type sent by github
+	headerGithubEvent = "X-GitHub-Event"
+	//headerGithubDelivery the delivery ID sent by github
+	headerGithubDelivery = "X-GitHub-Delivery"
+)
+
+type githubMode struct {
+	baseMode
+}
+
+func (githubMode) ID() uint8 {
+	return 3
+}
+
+func (githubMode) Name() string {
+	return "github"
+}
+
+func (githubMode) EventType(header http.Header, payload []byte) string {
+	return header.Get(headerGithubEvent)
+}
+
+func (githubMode) VerifySignature(header http.Header, payload []byte, secrets []string) error {
+	return verifySHA256(header.Get(headerGithubSignature), "sha256=", secrets, payload)
+}
+
+func (githubMode) DeliveryID(header http.Header) string {
+	return header.Get(headerGithubDelivery)
+}
+
+//Github sends a ping after a webhook was created
+func (githubMode) Handshake(header http.Header, payload []byte) *HandshakeResponse {
+	if header.Get(headerGithubEvent) != "ping" {
+		return nil
+	}
+
+	return &HandshakeResponse{
+		StatusCode:  http.StatusOK,
+		ContentType: "text/plain",
+		Body:        []byte("pong"),
+	}
+}
+
+func (githubMode) SecretHeaders() []string {
+	return []string{headerGithubSignature, headerGithubLegacySignature}
+}
@@ -0,0 +1,45 @@
+package modes
+
+import (
+	"net/http"
+	"strings"
+)
+
+const (
+	//headerGitlabToken the secret token sent by gitlab
+	headerGitlabToken = "X-Gitlab-Token"
+	//headerGitlabEvent the event type sent by gitlab
+	headerGitlabEvent = "X-Gitlab-Event"
+	//headerGitlabDelivery the delivery ID sent by gitlab
+	headerGitlabDelivery = "X-Gitlab-Event-UUID"
+)
+
+type gitlabMode struct {
+	baseMode
+}
+
+func (gitlabMode) ID() uint8 {
+	return 1
+}
+
+func (gitlabMode) Name() string {
+	return "gitlab"
+}
+
+//Gitlab sends events like 'Push Hook'
+func (gitlabMode) EventType(header http.Header, payload []byte) string {
+	event := strings.TrimSpace(header.Get(headerGitlabEvent))
+	return strings.TrimSuffix(strings.ToLower(event), " hook")
+}
+
+func (gitlabMode) VerifySignature(header http.Header, payload []byte, secrets []string) error {
+	return verifyToken(header.Get(headerGitlabToken), secrets)
+}
+
+func (gitlabMode) DeliveryID(header http.Header) string {
+	return header.Get(headerGitlabDelivery)
+}
+
+func (gitlabMode) SecretHeaders() []string {
+	return []string{headerGitlabToken}
+}
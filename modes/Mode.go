@@ -0,0 +1,148 @@
+package modes
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+//Mode a provider sending webhooks
+type Mode interface {
+	//ID the ID of the mode stored in sources
+	ID() uint8
+	//Name the unique name of the mode
+	Name() string
+	//EventType extracts the event type of a webhook
+	EventType(header http.Header, payload []byte) string
+	//VerifySignature verifies the proof of the provider using one of the secrets
+	VerifySignature(header http.Header, payload []byte, secrets []string) error
+	//DeliveryID returns the unique ID of a delivery or an empty string if the provider doesn't send one
+	DeliveryID(header http.Header) string
+	//DefaultFilters JSON objects which are always removed from payloads
+	DefaultFilters() []string
+	//Handshake returns a response if the webhook is a verification request of the provider.
+	//Verification requests are answered directly and not forwarded
+	Handshake(header http.Header, payload []byte) *HandshakeResponse
+	//SecretHeaders headers containing the secret or signature of the provider.
+	//They are removed after the verification and never stored or forwarded
+	SecretHeaders() []string
+}
+
+//HandshakeResponse the response to a verification request of a provider
+type HandshakeResponse struct {
+	StatusCode  int
+	ContentType string
+	Body        []byte
+}
+
+var (
+	//ErrMissingSignature error if a webhook contains no proof of its origin
+	ErrMissingSignature = errors.New("missing signature")
+	//ErrInvalidSignature error if the proof of a webhook doesn't match
+	ErrInvalidSignature = errors.New("invalid signature")
+)
+
+var (
+	registryMutex sync.RWMutex
+	modesByID     = make(map[uint8]Mode)
+	modesByName   = make(map[string]Mode)
+)
+
+func init() {
+	for _, mode := range []Mode{
+		customMode{},
+		gitlabMode{},
+		dockerMode{},
+		githubMode{},
+		giteaMode{},
+		bitbucketMode{},
+	} {
+		if err := Register(mode); err != nil {
+			panic(err)
+		}
+	}
+}
+
+//Register adds a mode to the registry. IDs and names must be unique
+func Register(mode Mode) error {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	if len(mode.Name()) == 0 {
+		return fmt.Errorf("mode %d has no name", mode.ID())
+	}
+	if m, has := modesByID[mode.ID()]; has {
+		return fmt.Errorf("mode ID %d of '%s' is already used by '%s'", mode.ID(), mode.Name(), m.Name())
+	}
+	if _, has := modesByName[mode.Name()]; has {
+		return fmt.Errorf("mode '%s' already exists", mode.Name())
+	}
+
+	modesByID[mode.ID()] = mode
+	modesByName[mode.Name()] = mode
+	return nil
+}
+
+//Get returns the mode with the given ID
+func Get(id uint8) (Mode, bool) {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	mode, has := modesByID[id]
+	return mode, has
+}
+
+//GetByName returns the mode with the given name
+func GetByName(name string) (Mode, bool) {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	mode, has := modesByName[name]
+	return mode, has
+}
+
+//NameOf returns the name of the mode with the given ID or an empty string if it doesn't exist
+func NameOf(id uint8) string {
+	if mode, has := Get(id); has {
+		return mode.Name()
+	}
+	return ""
+}
+
+//All returns all registered modes ordered by their ID
+func All() []Mode {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	modes := make([]Mode, 0, len(modesByID))
+	for _, mode := range modesByID {
+		modes = append(modes, mode)
+	}
+
+	sort.Slice(modes, func(i, j int) bool {
+		return modes[i].ID() < modes[j].ID()
+	})
+
+	return modes
+}
+
+//baseMode default implementation of the optional parts of a mode
+type baseMode struct{}
+
+func (baseMode) DeliveryID(header http.Header) string {
+	return ""
+}
+
+func (baseMode) DefaultFilters() []string {
+	return nil
+}
+
+func (baseMode) Handshake(header http.Header, payload []byte) *HandshakeResponse {
+	return nil
+}
+
+func (baseMode) SecretHeaders() []string {
+	return nil
+}
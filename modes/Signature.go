@@ -0,0 +1,72 @@
+package modes
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"hash"
+	"strings"
+)
+
+//VerifyHMAC verifies a hex encoded HMAC signature of payload.
+//The signature is valid if it was created using one of the secrets
+func VerifyHMAC(hashFunc func() hash.Hash, signature, prefix string, secrets []string, payload []byte) error {
+	if len(signature) == 0 {
+		return ErrMissingSignature
+	}
+
+	sig, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(signature), prefix))
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	return verifyHMAC(hashFunc, sig, secrets, payload)
+}
+
+//verifyHMAC verifies the raw HMAC signature of payload
+func verifyHMAC(hashFunc func() hash.Hash, sig []byte, secrets []string, payload []byte) error {
+	for _, secret := range secrets {
+		mac := hmac.New(hashFunc, []byte(secret))
+		mac.Write(payload)
+
+		if hmac.Equal(sig, mac.Sum(nil)) {
+			return nil
+		}
+	}
+
+	return ErrInvalidSignature
+}
+
+//verifySHA256 verifies a hex encoded HMAC-SHA256 signature
+func verifySHA256(signature, prefix string, secrets []string, payload []byte) error {
+	return VerifyHMAC(sha256.New, signature, prefix, secrets, payload)
+}
+
+//verifyToken verifies a plain token sent by the provider
+func verifyToken(token string, secrets []string) error {
+	if len(token) == 0 {
+		return ErrMissingSignature
+	}
+
+	if !MatchesAnySecret(token, secrets) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+//MatchesAnySecret return true if inp equals one of the secrets
+func MatchesAnySecret(inp string, secrets []string) bool {
+	for _, secret := range secrets {
+		if secretEquals(inp, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+//Compare secrets in constant time
+func secretEquals(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
@@ -0,0 +1,154 @@
+package modes
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"hash"
+	"net/http"
+	"testing"
+)
+
+const (
+	testSecret  = "secret"
+	testPayload = `{"ref":"refs/heads/main"}`
+)
+
+func sign(hashFunc func() hash.Hash, secret, payload string) []byte {
+	mac := hmac.New(hashFunc, []byte(secret))
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+func hexSignature(hashFunc func() hash.Hash, secret string) string {
+	return hex.EncodeToString(sign(hashFunc, secret, testPayload))
+}
+
+func TestVerifySignature(t *testing.T) {
+	valid256 := hexSignature(sha256.New, testSecret)
+	wrongSecret := hexSignature(sha256.New, "other")
+
+	tests := []struct {
+		name    string
+		mode    string
+		header  string
+		value   string
+		secrets []string
+		err     error
+	}{
+		{"custom valid", "custom", headerCustomSignature, "sha256=" + valid256, []string{testSecret}, nil},
+		{"custom without prefix", "custom", headerCustomSignature, valid256, []string{testSecret}, nil},
+		{"custom wrong secret", "custom", headerCustomSignature, "sha256=" + wrongSecret, []string{testSecret}, ErrInvalidSignature},
+		{"custom missing", "custom", "", "", []string{testSecret}, ErrMissingSignature},
+		{"custom no hex", "custom", headerCustomSignature, "sha256=xyz", []string{testSecret}, ErrInvalidSignature},
+		{"custom previous secret", "custom", headerCustomSignature, "sha256=" + valid256, []string{"new", testSecret}, nil},
+		{"custom no secrets", "custom", headerCustomSignature, "sha256=" + valid256, nil, ErrInvalidSignature},
+
+		{"github valid", "github", headerGithubSignature, "sha256=" + valid256, []string{testSecret}, nil},
+		{"github wrong secret", "github", headerGithubSignature, "sha256=" + wrongSecret, []string{testSecret}, ErrInvalidSignature},
+		{"github legacy only", "github", headerGithubLegacySignature, "sha1=" + hexSignature(sha1.New, testSecret), []string{testSecret}, ErrMissingSignature},
+		{"github missing", "github", "", "", []string{testSecret}, ErrMissingSignature},
+
+		{"gitlab valid", "gitlab", headerGitlabToken, testSecret, []string{testSecret}, nil},
+		{"gitlab previous secret", "gitlab", headerGitlabToken, testSecret, []string{"new", testSecret}, nil},
+		{"gitlab wrong token", "gitlab", headerGitlabToken, "other", []string{testSecret}, ErrInvalidSignature},
+		{"gitlab prefix of secret", "gitlab", headerGitlabToken, "sec", []string{testSecret}, ErrInvalidSignature},
+		{"gitlab missing", "gitlab", "", "", []string{testSecret}, ErrMissingSignature},
+
+		{"bitbucket valid", "bitbucket", headerBitbucketSignature, "sha256=" + valid256, []string{testSecret}, nil},
+		{"bitbucket wrong secret", "bitbucket", headerBitbucketSignature, "sha256=" + wrongSecret, []string{testSecret}, ErrInvalidSignature},
+		{"bitbucket missing", "bitbucket", "", "", []string{testSecret}, ErrMissingSignature},
+
+		{"gitea valid", "gitea", headerGiteaSignature, valid256, []string{testSecret}, nil},
+		{"gitea wrong secret", "gitea", headerGiteaSignature, wrongSecret, []string{testSecret}, ErrInvalidSignature},
+
+		{"docker unsupported", "docker", "", "", []string{testSecret}, ErrMissingSignature},
+	}
+
+	for _, test := range tests {
+		mode, has := GetByName(test.mode)
+		if !has {
+			t.Fatalf("%s: mode %s not registered", test.name, test.mode)
+		}
+
+		header := http.Header{}
+		if len(test.header) > 0 {
+			header.Set(test.header, test.value)
+		}
+
+		if err := mode.VerifySignature(header, []byte(testPayload), test.secrets); err != test.err {
+			t.Errorf("%s: expected %v, got %v", test.name, test.err, err)
+		}
+	}
+}
+
+func TestVerifySignatureModifiedPayload(t *testing.T) {
+	header := http.Header{}
+	header.Set(headerGithubSignature, "sha256="+hexSignature(sha256.New, testSecret))
+
+	mode, _ := GetByName("github")
+	if err := mode.VerifySignature(header, []byte(testPayload+" "), []string{testSecret}); err != ErrInvalidSignature {
+		t.Errorf("expected ErrInvalidSignature, got %v", err)
+	}
+}
+
+func TestDeclarativeVerifySignature(t *testing.T) {
+	tests := []struct {
+		name       string
+		definition Definition
+		value      string
+		err        error
+	}{
+		{"sha1 hex", Definition{HMACAlgorithm: "sha1", SignatureHeader: "X-Sig"}, hexSignature(sha1.New, testSecret), nil},
+		{"sha256 prefix", Definition{HMACAlgorithm: "SHA256", SignatureHeader: "X-Sig", SignaturePrefix: "v1="}, "v1=" + hexSignature(sha256.New, testSecret), nil},
+		{"sha512 base64", Definition{HMACAlgorithm: "sha512", SignatureHeader: "X-Sig", SignatureEncoding: "base64"}, base64.StdEncoding.EncodeToString(sign(sha512.New, testSecret, testPayload)), nil},
+		{"wrong algorithm", Definition{HMACAlgorithm: "sha512", SignatureHeader: "X-Sig"}, hexSignature(sha256.New, testSecret), ErrInvalidSignature},
+		{"wrong secret", Definition{HMACAlgorithm: "sha256", SignatureHeader: "X-Sig"}, hexSignature(sha256.New, "other"), ErrInvalidSignature},
+		{"wrong encoding", Definition{HMACAlgorithm: "sha256", SignatureHeader: "X-Sig", SignatureEncoding: "base64"}, "%%%", ErrInvalidSignature},
+		{"missing", Definition{HMACAlgorithm: "sha256", SignatureHeader: "X-Sig"}, "", ErrMissingSignature},
+		{"token", Definition{HMACAlgorithm: "token", SignatureHeader: "X-Sig"}, testSecret, nil},
+		{"token with prefix", Definition{HMACAlgorithm: "token", SignatureHeader: "X-Sig", SignaturePrefix: "Bearer "}, "Bearer " + testSecret, nil},
+		{"wrong token", Definition{HMACAlgorithm: "token", SignatureHeader: "X-Sig"}, "other", ErrInvalidSignature},
+		{"no algorithm", Definition{}, testSecret, ErrMissingSignature},
+	}
+
+	for _, test := range tests {
+		test.definition.Name = "test"
+		mode, err := NewDeclarativeMode(test.definition)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err.Error())
+			continue
+		}
+
+		header := http.Header{}
+		if len(test.value) > 0 {
+			header.Set("X-Sig", test.value)
+		}
+
+		if err = mode.VerifySignature(header, []byte(testPayload), []string{testSecret}); err != test.err {
+			t.Errorf("%s: expected %v, got %v", test.name, test.err, err)
+		}
+	}
+}
+
+func TestNewDeclarativeModeErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		definition Definition
+	}{
+		{"invalid name", Definition{Name: "My Mode"}},
+		{"unsupported algorithm", Definition{Name: "test", HMACAlgorithm: "md5", SignatureHeader: "X-Sig"}},
+		{"missing signature header", Definition{Name: "test", HMACAlgorithm: "sha256"}},
+		{"unsupported encoding", Definition{Name: "test", HMACAlgorithm: "sha256", SignatureHeader: "X-Sig", SignatureEncoding: "base32"}},
+		{"invalid event path", Definition{Name: "test", EventPath: "$.a..b"}},
+	}
+
+	for _, test := range tests {
+		if _, err := NewDeclarativeMode(test.definition); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+}
@@ -23,8 +23,8 @@ type APIService struct {
 }
 
 //NewAPIService create new API service
-func NewAPIService(db *dbhelper.DBhelper, config *models.ConfigStruct, ownIP *string, callback models.SubscriberNotifyCallback) *APIService {
-	router := handlers.NewRouter(db, config, ownIP, callback)
+func NewAPIService(db *dbhelper.DBhelper, config *models.ConfigStruct, ownIP *string, callback models.Outbox, notifyCallback models.NotifyCallback, scheduler models.DeliveryScheduler) *APIService {
+	router := handlers.NewRouter(db, config, ownIP, callback, notifyCallback, scheduler)
 
 	var httpServer, httpsServer *http.Server
 
@@ -36,12 +36,34 @@ func (service CleanupService) Tick() <-chan error {
 }
 
 func (service CleanupService) clean() error {
-	//Magic query. Cleans up old webhooks
-	_, err := service.db.Execf("DELETE FROM %s WHERE (%s.received < (SELECT MIN(lastTrigger) FROM %s WHERE %s.source = %s.sourceID) AND DATE_ADD(received, INTERVAL 1 day) <= now()) OR DATE_ADD(received, INTERVAL 2 day) <= now()", []string{models.TableWebhooks, models.TableWebhooks, models.TableSubscriptions, models.TableSubscriptions, models.TableWebhooks})
+	//Magic query. Cleans up old webhooks which were handed over to the subscribers
+	_, err := service.db.Execf("DELETE FROM %s WHERE ((%s.received < (SELECT MIN(lastTrigger) FROM %s WHERE %s.source = %s.sourceID) AND DATE_ADD(received, INTERVAL 1 day) <= now()) OR DATE_ADD(received, INTERVAL 2 day) <= now()) AND pk_id NOT IN (SELECT webhookID FROM %s) AND pk_id NOT IN (SELECT webhookID FROM %s) AND sourceID NOT IN (SELECT pk_id FROM %s WHERE paused = 1)", []string{models.TableWebhooks, models.TableWebhooks, models.TableSubscriptions, models.TableSubscriptions, models.TableWebhooks, models.TableOutbox, models.TableOrderedQueue, models.TableSources})
 	if err != nil {
 		return err
 	}
 
+	//Delete old secrets which are out of their grace period
+	err = models.DeleteExpiredSecrets(service.db)
+	if err != nil {
+		return err
+	}
+
+	//Delete delivery IDs which are out of the deduplication window
+	if service.config.Server.DeduplicationWindow > 0 {
+		err = models.DeleteOldDeliveryIDs(service.db, time.Now().Add(-service.config.Server.DeduplicationWindow))
+		if err != nil {
+			return err
+		}
+	}
+
+	//Delete old delivery attempts
+	if service.config.Server.KeepAttemptsFor > 0 {
+		err = models.DeleteOldDeliveryAttempts(service.db, time.Now().Add(-service.config.Server.KeepAttemptsFor))
+		if err != nil {
+			return err
+		}
+	}
+
 	//Delete old loginsessions
 	if service.config.Server.CleanSessionsAfter.Seconds() > 0 {
 		minTime := time.Now().Unix() - int64(service.config.Server.CleanSessionsAfter.Seconds())
@@ -0,0 +1,298 @@
+package services
+
+import (
+	"sync"
+
+	dbhelper "github.com/JojiiOfficial/GoDBHelper"
+	"github.com/JojiiOfficial/WhShareServer/models"
+	log "github.com/sirupsen/logrus"
+)
+
+//DeliveryService delivers webhooks to the subscriptions using a fixed count of workers.
+//Queued deliveries are shared round-robin across sources and their subscriptions
+type DeliveryService struct {
+	db       *dbhelper.DBhelper
+	workers  int
+	capacity int
+	Callback models.NotifyCallback
+
+	mutex sync.Mutex
+	cond  *sync.Cond
+
+	//Sources with queued deliveries
+	sources     []*sourceQueue
+	sourceIndex map[uint32]*sourceQueue
+	next        int
+
+	queued         int
+	inFlight       int
+	subsInFlight   map[uint32]int
+	sourceInFlight map[uint32]int
+
+	//Max deliveries in flight per subscription and source. 0 means unlimited
+	maxSubsInFlight   int
+	maxSourceInFlight int
+
+	//Ordered subscriptions waiting for the retry of a failed webhook
+	held map[uint32]bool
+}
+
+type sourceQueue struct {
+	sourcePK      uint32
+	subscriptions []*subscriptionQueue
+	next          int
+}
+
+type subscriptionQueue struct {
+	subscriptionPK uint32
+	deliveries     []models.Delivery
+}
+
+//NewDeliveryService create a new DeliveryService
+func NewDeliveryService(db *dbhelper.DBhelper, config *models.ConfigStruct) *DeliveryService {
+	service := &DeliveryService{
+		db:                db,
+		workers:           config.Server.WorkerCount,
+		capacity:          config.Server.DeliveryQueueSize,
+		sourceIndex:       make(map[uint32]*sourceQueue),
+		subsInFlight:      make(map[uint32]int),
+		sourceInFlight:    make(map[uint32]int),
+		maxSubsInFlight:   config.Server.MaxInFlightPerSubs,
+		maxSourceInFlight: config.Server.MaxInFlightPerSource,
+		held:              make(map[uint32]bool),
+	}
+	service.cond = sync.NewCond(&service.mutex)
+
+	return service
+}
+
+//Start starts the workers of the DeliveryService
+func (service *DeliveryService) Start() {
+	for i := 0; i < service.workers; i++ {
+		go service.work()
+	}
+}
+
+//Schedule queues all deliveries or none of them if the queue has not enough space left.
+//An empty queue accepts any count of deliveries, so webhooks with many subscriptions can't get stuck
+func (service *DeliveryService) Schedule(deliveries ...models.Delivery) error {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+
+	if service.queued > 0 && service.queued+len(deliveries) > service.capacity {
+		return models.ErrQueueFull
+	}
+
+	for _, delivery := range deliveries {
+		service.enqueue(delivery)
+	}
+
+	service.cond.Broadcast()
+	return nil
+}
+
+//Stats returns the current state of the queue
+func (service *DeliveryService) Stats() models.DeliveryStats {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+
+	return models.DeliveryStats{
+		Workers:  service.workers,
+		Capacity: service.capacity,
+		Queued:   service.queued,
+		InFlight: service.inFlight,
+		Sources:  len(service.sources),
+	}
+}
+
+func (service *DeliveryService) work() {
+	for {
+		service.mutex.Lock()
+		delivery, ok := service.dequeue()
+		for !ok {
+			service.cond.Wait()
+			delivery, ok = service.dequeue()
+		}
+
+		service.inFlight++
+		service.subsInFlight[delivery.Subscription.PkID]++
+		service.sourceInFlight[delivery.Source.PkID]++
+		service.mutex.Unlock()
+
+		if delivery.Subscription.Ordered {
+			service.deliverOrdered(delivery)
+		} else {
+			log.Debugf("Delivering webhook %d to subscription %d\n", delivery.Webhook.PkID, delivery.Subscription.PkID)
+			delivery.Subscription.Notify(service.db, delivery.Webhook, delivery.Source, service.Callback)
+		}
+
+		service.mutex.Lock()
+		service.inFlight--
+		if service.subsInFlight[delivery.Subscription.PkID]--; service.subsInFlight[delivery.Subscription.PkID] == 0 {
+			delete(service.subsInFlight, delivery.Subscription.PkID)
+		}
+		if service.sourceInFlight[delivery.Source.PkID]--; service.sourceInFlight[delivery.Source.PkID] == 0 {
+			delete(service.sourceInFlight, delivery.Source.PkID)
+		}
+
+		//Deliveries of the subscription and source might be ready now
+		service.cond.Broadcast()
+		service.mutex.Unlock()
+	}
+}
+
+//deliverOrdered delivers the webhooks of an ordered subscription in the order they were received.
+//After a failed delivery later webhooks are held back until the failed one was delivered successfully
+func (service *DeliveryService) deliverOrdered(delivery models.Delivery) {
+	//Reload the subscription to get its current settings
+	subscription, err := models.GetSubscriptionByPK(service.db, delivery.Subscription.PkID)
+	if err != nil {
+		if err.Error() != dbhelper.ErrNoRowsInResultSet {
+			LogError(err)
+		}
+		return
+	}
+
+	webhook := delivery.Webhook
+	if webhook == nil {
+		//The retry of the failed webhook continues the delivery
+		if service.isHeld(subscription.PkID) {
+			return
+		}
+
+		if paused, err := models.IsSourcePaused(service.db, subscription.Source); err != nil || paused {
+			return
+		}
+
+		if webhook, err = subscription.GetNextOrderedWebhook(service.db); err != nil || webhook == nil {
+			LogError(err)
+			return
+		}
+	}
+
+	log.Debugf("Delivering webhook %d to ordered subscription %d\n", webhook.PkID, subscription.PkID)
+	response, err := subscription.Notify(service.db, webhook, delivery.Source, service.Callback)
+
+	//Replays are delivered outside of the order and don't affect the queue
+	if webhook.IsReplay {
+		return
+	}
+
+	if err != nil || !response.IsSuccess() {
+		//Notify created a retry of the webhook which continues the delivery
+		service.setHeld(subscription.PkID, true)
+		return
+	}
+
+	if LogError(subscription.RemoveOrderedWebhook(service.db, webhook.PkID)) {
+		return
+	}
+
+	//The subscriber is reachable. Continue with the next webhook
+	service.setHeld(subscription.PkID, false)
+
+	service.mutex.Lock()
+	service.enqueue(models.Delivery{
+		Source:       delivery.Source,
+		Subscription: subscription,
+		Ordered:      true,
+	})
+	service.cond.Broadcast()
+	service.mutex.Unlock()
+}
+
+func (service *DeliveryService) isHeld(subscriptionPK uint32) bool {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	return service.held[subscriptionPK]
+}
+
+func (service *DeliveryService) setHeld(subscriptionPK uint32, held bool) {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+
+	if held {
+		service.held[subscriptionPK] = true
+	} else {
+		delete(service.held, subscriptionPK)
+	}
+}
+
+//enqueue appends the delivery to the queue of its subscription
+func (service *DeliveryService) enqueue(delivery models.Delivery) {
+	source, has := service.sourceIndex[delivery.Source.PkID]
+	if !has {
+		source = &sourceQueue{sourcePK: delivery.Source.PkID}
+		service.sourceIndex[source.sourcePK] = source
+		service.sources = append(service.sources, source)
+	}
+
+	var subscription *subscriptionQueue
+	for _, queue := range source.subscriptions {
+		if queue.subscriptionPK == delivery.Subscription.PkID {
+			subscription = queue
+			break
+		}
+	}
+
+	if subscription == nil {
+		subscription = &subscriptionQueue{subscriptionPK: delivery.Subscription.PkID}
+		source.subscriptions = append(source.subscriptions, subscription)
+	}
+
+	//A queued delivery of the next webhook covers all webhooks received until it starts
+	if delivery.Webhook == nil {
+		for _, queued := range subscription.deliveries {
+			if queued.Webhook == nil {
+				return
+			}
+		}
+	}
+
+	subscription.deliveries = append(subscription.deliveries, delivery)
+	service.queued++
+}
+
+//dequeue returns the next delivery which is ready. Sources and their subscriptions take turns.
+//Sources and subscriptions which reached their limit of deliveries in flight are skipped
+func (service *DeliveryService) dequeue() (models.Delivery, bool) {
+	for i := 0; i < len(service.sources); i++ {
+		sourcePos := (service.next + i) % len(service.sources)
+		source := service.sources[sourcePos]
+		if service.maxSourceInFlight > 0 && service.sourceInFlight[source.sourcePK] >= service.maxSourceInFlight {
+			continue
+		}
+
+		for j := 0; j < len(source.subscriptions); j++ {
+			subsPos := (source.next + j) % len(source.subscriptions)
+			subscription := source.subscriptions[subsPos]
+
+			delivery := subscription.deliveries[0]
+			inFlight := service.subsInFlight[subscription.subscriptionPK]
+			if (delivery.Ordered && inFlight > 0) || (service.maxSubsInFlight > 0 && inFlight >= service.maxSubsInFlight) {
+				continue
+			}
+
+			subscription.deliveries = subscription.deliveries[1:]
+			service.queued--
+
+			//Continue with the next subscription and source next time
+			source.next = subsPos + 1
+			if len(subscription.deliveries) == 0 {
+				source.subscriptions = append(source.subscriptions[:subsPos], source.subscriptions[subsPos+1:]...)
+				source.next = subsPos
+			}
+
+			service.next = sourcePos + 1
+			if len(source.subscriptions) == 0 {
+				delete(service.sourceIndex, source.sourcePK)
+				service.sources = append(service.sources[:sourcePos], service.sources[sourcePos+1:]...)
+				service.next = sourcePos
+			}
+
+			return delivery, true
+		}
+	}
+
+	return models.Delivery{}, false
+}
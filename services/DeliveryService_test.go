@@ -0,0 +1,352 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/JojiiOfficial/WhShareServer/models"
+)
+
+func newTestDeliveryService(capacity, maxSubsInFlight, maxSourceInFlight int) *DeliveryService {
+	config := &models.ConfigStruct{}
+	config.Server.WorkerCount = 1
+	config.Server.DeliveryQueueSize = capacity
+	config.Server.MaxInFlightPerSubs = maxSubsInFlight
+	config.Server.MaxInFlightPerSource = maxSourceInFlight
+
+	return NewDeliveryService(nil, config)
+}
+
+func testDelivery(sourcePK, subscriptionPK, webhookPK uint32) models.Delivery {
+	return models.Delivery{
+		Source:       &models.Source{PkID: sourcePK},
+		Subscription: &models.Subscription{PkID: subscriptionPK},
+		Webhook:      &models.Webhook{PkID: webhookPK},
+	}
+}
+
+//Pump deliveries of ordered subscriptions load the next webhook themselves
+func pumpDelivery(sourcePK, subscriptionPK uint32) models.Delivery {
+	return models.Delivery{
+		Source:       &models.Source{PkID: sourcePK},
+		Subscription: &models.Subscription{PkID: subscriptionPK, Ordered: true},
+		Ordered:      true,
+	}
+}
+
+//Take the deliveries like a worker would and mark them as in flight
+func takeDeliveries(service *DeliveryService, n int) []models.Delivery {
+	var deliveries []models.Delivery
+	for i := 0; i < n; i++ {
+		delivery, ok := service.dequeue()
+		if !ok {
+			break
+		}
+
+		service.inFlight++
+		service.subsInFlight[delivery.Subscription.PkID]++
+		service.sourceInFlight[delivery.Source.PkID]++
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries
+}
+
+//Mark a delivery as done like a worker would
+func finishDelivery(service *DeliveryService, delivery models.Delivery) {
+	service.inFlight--
+	if service.subsInFlight[delivery.Subscription.PkID]--; service.subsInFlight[delivery.Subscription.PkID] == 0 {
+		delete(service.subsInFlight, delivery.Subscription.PkID)
+	}
+	if service.sourceInFlight[delivery.Source.PkID]--; service.sourceInFlight[delivery.Source.PkID] == 0 {
+		delete(service.sourceInFlight, delivery.Source.PkID)
+	}
+}
+
+func TestDeliveryServiceRoundRobin(t *testing.T) {
+	tests := []struct {
+		name       string
+		deliveries []models.Delivery
+		//Expected order as subscription PKs
+		order []uint32
+	}{
+		{"single subscription keeps order", []models.Delivery{
+			testDelivery(1, 10, 1), testDelivery(1, 10, 2), testDelivery(1, 10, 3),
+		}, []uint32{10, 10, 10}},
+		{"subscriptions of a source take turns", []models.Delivery{
+			testDelivery(1, 10, 1), testDelivery(1, 10, 2), testDelivery(1, 11, 1), testDelivery(1, 11, 2),
+		}, []uint32{10, 11, 10, 11}},
+		{"sources take turns", []models.Delivery{
+			testDelivery(1, 10, 1), testDelivery(1, 10, 2), testDelivery(1, 10, 3), testDelivery(2, 20, 4),
+		}, []uint32{10, 20, 10, 10}},
+		{"busy source doesn't starve others", []models.Delivery{
+			testDelivery(1, 10, 1), testDelivery(1, 11, 1), testDelivery(1, 12, 1), testDelivery(2, 20, 2), testDelivery(2, 20, 3),
+		}, []uint32{10, 20, 11, 20, 12}},
+	}
+
+	for _, test := range tests {
+		service := newTestDeliveryService(100, 0, 0)
+		if err := service.Schedule(test.deliveries...); err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err.Error())
+			continue
+		}
+
+		var order []uint32
+		for {
+			delivery, ok := service.dequeue()
+			if !ok {
+				break
+			}
+			order = append(order, delivery.Subscription.PkID)
+		}
+
+		if !equalPKs(order, test.order) {
+			t.Errorf("%s: expected order %v, got %v", test.name, test.order, order)
+		}
+
+		if service.queued != 0 || len(service.sources) != 0 || len(service.sourceIndex) != 0 {
+			t.Errorf("%s: expected empty queue, got %d queued in %d sources", test.name, service.queued, len(service.sources))
+		}
+	}
+}
+
+func TestDeliveryServiceCapacity(t *testing.T) {
+	tests := []struct {
+		name     string
+		capacity int
+		batches  []int
+		errs     []error
+	}{
+		{"fits", 3, []int{1, 2}, []error{nil, nil}},
+		{"full", 3, []int{2, 2}, []error{nil, models.ErrQueueFull}},
+		{"all or nothing", 3, []int{2, 2, 1}, []error{nil, models.ErrQueueFull, nil}},
+		{"empty queue accepts oversized batch", 2, []int{5, 1}, []error{nil, models.ErrQueueFull}},
+	}
+
+	for _, test := range tests {
+		service := newTestDeliveryService(test.capacity, 0, 0)
+		var webhookPK uint32
+		queued := 0
+
+		for i, size := range test.batches {
+			deliveries := make([]models.Delivery, size)
+			for j := range deliveries {
+				webhookPK++
+				deliveries[j] = testDelivery(1, uint32(j), webhookPK)
+			}
+
+			err := service.Schedule(deliveries...)
+			if err != test.errs[i] {
+				t.Errorf("%s: batch %d: expected %v, got %v", test.name, i, test.errs[i], err)
+			}
+			if err == nil {
+				queued += size
+			}
+		}
+
+		if stats := service.Stats(); stats.Queued != queued || stats.Capacity != test.capacity {
+			t.Errorf("%s: expected %d queued, got %+v", test.name, queued, stats)
+		}
+	}
+}
+
+func TestDeliveryServiceInFlightLimits(t *testing.T) {
+	tests := []struct {
+		name              string
+		maxSubsInFlight   int
+		maxSourceInFlight int
+		deliveries        []models.Delivery
+		//Subscription PKs ready while nothing finished
+		ready []uint32
+	}{
+		{"unlimited", 0, 0, []models.Delivery{
+			testDelivery(1, 10, 1), testDelivery(1, 10, 2), testDelivery(1, 10, 3),
+		}, []uint32{10, 10, 10}},
+		{"per subscription", 1, 0, []models.Delivery{
+			testDelivery(1, 10, 1), testDelivery(1, 10, 2), testDelivery(1, 11, 1), testDelivery(1, 11, 2),
+		}, []uint32{10, 11}},
+		{"per source", 0, 2, []models.Delivery{
+			testDelivery(1, 10, 1), testDelivery(1, 11, 1), testDelivery(1, 12, 1), testDelivery(2, 20, 2),
+		}, []uint32{10, 20, 11}},
+		{"both", 2, 3, []models.Delivery{
+			testDelivery(1, 10, 1), testDelivery(1, 10, 2), testDelivery(1, 10, 3), testDelivery(1, 11, 1), testDelivery(1, 11, 2),
+		}, []uint32{10, 11, 10}},
+	}
+
+	for _, test := range tests {
+		service := newTestDeliveryService(100, test.maxSubsInFlight, test.maxSourceInFlight)
+		if err := service.Schedule(test.deliveries...); err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err.Error())
+			continue
+		}
+
+		taken := takeDeliveries(service, len(test.deliveries))
+
+		var ready []uint32
+		for _, delivery := range taken {
+			ready = append(ready, delivery.Subscription.PkID)
+		}
+		if !equalPKs(ready, test.ready) {
+			t.Errorf("%s: expected %v to be ready, got %v", test.name, test.ready, ready)
+		}
+
+		//Finished deliveries make the remaining ones ready
+		for _, delivery := range taken {
+			finishDelivery(service, delivery)
+		}
+
+		remaining := len(test.deliveries) - len(taken)
+		for len(taken) > 0 && remaining > 0 {
+			taken = takeDeliveries(service, remaining)
+			for _, delivery := range taken {
+				finishDelivery(service, delivery)
+			}
+			remaining -= len(taken)
+		}
+
+		if remaining != 0 || service.queued != 0 {
+			t.Errorf("%s: %d deliveries got stuck", test.name, remaining)
+		}
+	}
+}
+
+func TestDeliveryServiceOrdered(t *testing.T) {
+	service := newTestDeliveryService(100, 0, 0)
+
+	//A queued pump covers all webhooks received until it starts
+	for i := 0; i < 3; i++ {
+		if err := service.Schedule(pumpDelivery(1, 10)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if service.queued != 1 {
+		t.Fatalf("expected pumps to be coalesced, got %d queued", service.queued)
+	}
+
+	taken := takeDeliveries(service, 1)
+	if len(taken) != 1 || taken[0].Webhook != nil {
+		t.Fatalf("expected a pump delivery, got %+v", taken)
+	}
+
+	//Only one delivery of an ordered subscription is in flight
+	replay := testDelivery(1, 10, 5)
+	replay.Ordered = true
+	if err := service.Schedule(pumpDelivery(1, 10), replay); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := service.dequeue(); ok {
+		t.Fatal("expected ordered subscription to wait for its delivery in flight")
+	}
+
+	//Other subscriptions aren't affected
+	if err := service.Schedule(testDelivery(1, 11, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if delivery, ok := service.dequeue(); !ok || delivery.Subscription.PkID != 11 {
+		t.Fatalf("expected delivery of subscription 11, got %+v", delivery)
+	}
+
+	finishDelivery(service, taken[0])
+
+	//Pump and replay keep their order
+	next := takeDeliveries(service, 2)
+	if len(next) != 1 || next[0].Webhook != nil {
+		t.Fatalf("expected the pump delivery, got %+v", next)
+	}
+	finishDelivery(service, next[0])
+
+	next = takeDeliveries(service, 1)
+	if len(next) != 1 || next[0].Webhook == nil || next[0].Webhook.PkID != 5 {
+		t.Fatalf("expected the replay, got %+v", next)
+	}
+}
+
+func TestDeliveryServiceHeld(t *testing.T) {
+	tests := []struct {
+		name  string
+		calls []bool
+		held  bool
+	}{
+		{"not held", nil, false},
+		{"held after failure", []bool{true}, true},
+		{"held twice", []bool{true, true}, true},
+		{"resumed after success", []bool{true, false}, false},
+		{"held again", []bool{true, false, true}, true},
+	}
+
+	for _, test := range tests {
+		service := newTestDeliveryService(100, 0, 0)
+		for _, held := range test.calls {
+			service.setHeld(10, held)
+		}
+
+		if held := service.isHeld(10); held != test.held {
+			t.Errorf("%s: expected held=%t, got %t", test.name, test.held, held)
+		}
+		if service.isHeld(11) {
+			t.Errorf("%s: expected other subscriptions not to be held", test.name)
+		}
+		if !test.held && len(service.held) != 0 {
+			t.Errorf("%s: expected resumed subscription to be removed", test.name)
+		}
+	}
+}
+
+func TestDeliveryServiceHeldResume(t *testing.T) {
+	service := newTestDeliveryService(100, 0, 0)
+
+	//A failed delivery holds the subscription. New webhooks only queue a pump
+	service.setHeld(10, true)
+	if err := service.Schedule(pumpDelivery(1, 10), pumpDelivery(1, 10)); err != nil {
+		t.Fatal(err)
+	}
+
+	//The retry of the failed webhook is delivered while the subscription is held
+	retry := testDelivery(1, 10, 1)
+	retry.Ordered = true
+	if err := service.Schedule(retry); err != nil {
+		t.Fatal(err)
+	}
+
+	if service.queued != 2 {
+		t.Fatalf("expected a pump and the retry to be queued, got %d", service.queued)
+	}
+
+	pump := takeDeliveries(service, 1)
+	if len(pump) != 1 || pump[0].Webhook != nil {
+		t.Fatalf("expected the pump delivery, got %+v", pump)
+	}
+	if !service.isHeld(10) {
+		t.Fatal("expected subscription to stay held")
+	}
+	finishDelivery(service, pump[0])
+
+	next := takeDeliveries(service, 1)
+	if len(next) != 1 || next[0].Webhook == nil || next[0].Webhook.PkID != 1 {
+		t.Fatalf("expected the retry, got %+v", next)
+	}
+	finishDelivery(service, next[0])
+
+	//The successful retry resumes the subscription and continues with a pump
+	service.setHeld(10, false)
+	if err := service.Schedule(pumpDelivery(1, 10)); err != nil {
+		t.Fatal(err)
+	}
+
+	if service.isHeld(10) {
+		t.Fatal("expected subscription to be resumed")
+	}
+	if next = takeDeliveries(service, 1); len(next) != 1 || next[0].Webhook != nil {
+		t.Fatalf("expected the pump delivery after resuming, got %+v", next)
+	}
+}
+
+func equalPKs(a, b []uint32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"time"
+
+	dbhelper "github.com/JojiiOfficial/GoDBHelper"
+	"github.com/JojiiOfficial/WhShareServer/models"
+	log "github.com/sirupsen/logrus"
+)
+
+//Count of outbox entries handled at once
+const outboxBatchSize = 100
+
+//OutboxService hands stored webhooks over to the subscribers
+type OutboxService struct {
+	db       *dbhelper.DBhelper
+	interval time.Duration
+	wake     chan bool
+	Callback models.SubscriberNotifyCallback
+}
+
+//NewOutboxService create a new OutboxService
+func NewOutboxService(db *dbhelper.DBhelper, config *models.ConfigStruct) *OutboxService {
+	return &OutboxService{
+		db:       db,
+		interval: config.Server.OutboxInterval,
+		wake:     make(chan bool, 1),
+	}
+}
+
+//Start starts the OutboxService. Entries left from a previous run are handled immediately
+func (service *OutboxService) Start() {
+	go (func() {
+		for {
+			service.process()
+
+			select {
+			case <-service.wake:
+			case <-time.After(service.interval):
+			}
+		}
+	})()
+}
+
+//OnWebhookReceive wakes up the service after a webhook was stored
+func (service *OutboxService) OnWebhookReceive(*models.Webhook, *models.Source) error {
+	service.Wake()
+	return nil
+}
+
+//Wake makes the service handle the stored webhooks immediately
+func (service *OutboxService) Wake() {
+	select {
+	case service.wake <- true:
+	default:
+		//Service is already woken up
+	}
+}
+
+//Hand all entries over to the callback
+func (service *OutboxService) process() {
+	for {
+		entries, err := models.GetOutboxEntries(service.db, outboxBatchSize)
+		if LogError(err) {
+			return
+		}
+
+		for _, entry := range entries {
+			if !service.handle(entry) {
+				return
+			}
+		}
+
+		if len(entries) < outboxBatchSize {
+			return
+		}
+	}
+}
+
+//Return false if the entry couldn't be handled and should be retried later
+func (service *OutboxService) handle(entry models.OutboxEntry) bool {
+	webhook, err := models.GetWebhookByPK(service.db, entry.WebhookPK)
+	if err != nil {
+		if err.Error() == dbhelper.ErrNoRowsInResultSet {
+			//Webhook was deleted
+			return !LogError(entry.Delete(service.db))
+		}
+
+		LogError(err)
+		return false
+	}
+
+	source, err := models.GetSourceByPK(service.db, webhook.SourceID)
+	if err != nil {
+		if err.Error() == dbhelper.ErrNoRowsInResultSet {
+			//Source was deleted
+			return !LogError(entry.Delete(service.db))
+		}
+
+		LogError(err)
+		return false
+	}
+
+	//The source was paused after the entries were loaded
+	if source.IsPaused {
+		return true
+	}
+
+	webhook.ExcludedSubscription = entry.ExcludedSubscription
+
+	log.Debugf("Handing webhook %d over to subscribers\n", webhook.PkID)
+	if err = service.Callback.OnWebhookReceive(webhook, source); err != nil {
+		//Keep the entry until the delivery queue has space again
+		if err == models.ErrQueueFull {
+			log.Debug("Delivery queue full. Handing webhooks over later")
+		}
+		return false
+	}
+
+	return !LogError(entry.Delete(service.db))
+}
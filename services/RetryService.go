@@ -1,6 +1,7 @@
 package services
 
 import (
+	"sync"
 	"time"
 
 	dbhelper "github.com/JojiiOfficial/GoDBHelper"
@@ -10,8 +11,9 @@ import (
 
 //RetryService handles retries
 type RetryService struct {
-	//RetryList list of retries
-	RetryList map[uint32]*models.Retry
+	//Retries by subscription. Deliveries add and remove retries concurrently
+	mutex     sync.Mutex
+	retryList map[uint32]*models.Retry
 
 	//RetryTimes constant map of
 	RetryTimes map[uint8]time.Duration
@@ -19,13 +21,13 @@ type RetryService struct {
 	db *dbhelper.DBhelper
 
 	handlerInterval time.Duration
-	Callback        models.NotifyCallback
+	Scheduler       models.DeliveryScheduler
 }
 
 //NewRetryService create new retryService
 func NewRetryService(db *dbhelper.DBhelper, conf *models.ConfigStruct) *RetryService {
 	return &RetryService{
-		RetryList:       make(map[uint32]*models.Retry),
+		retryList:       make(map[uint32]*models.Retry),
 		RetryTimes:      conf.Server.Retries.RetryTimes,
 		handlerInterval: conf.Server.Retries.RetryInterval,
 		db:              db,
@@ -33,27 +35,62 @@ func NewRetryService(db *dbhelper.DBhelper, conf *models.ConfigStruct) *RetrySer
 }
 
 //Add adds a subscription to the retryService
-func (retryService *RetryService) Add(db *dbhelper.DBhelper, subscriptionPK, sourcePK, WebhookPK uint32) {
-	if _, ok := retryService.RetryList[subscriptionPK]; ok {
+func (retryService *RetryService) Add(db *dbhelper.DBhelper, subscription models.Subscription, sourcePK, WebhookPK uint32) {
+	retryService.mutex.Lock()
+	defer retryService.mutex.Unlock()
+
+	if _, ok := retryService.retryList[subscription.PkID]; ok {
 		return
 	}
 
-	retry, err := models.NewRetry(db, sourcePK, WebhookPK, retryService.getRetryTime(0))
+	retry, err := models.NewRetry(db, sourcePK, WebhookPK, retryService.getRetryTime(subscription, 0))
 	if err != nil {
 		log.Error("Error inserting retry. This retry might not be delivered on an app crash")
 	}
 
-	retryService.RetryList[subscriptionPK] = retry
+	retryService.retryList[subscription.PkID] = retry
 
 	log.Debug("Add new retry to list. Next retry: ", retry.NextRetry.Format(time.Stamp))
 }
 
-//Remove removes a subscription from the retryService
+//Remove removes the retry of a subscription from the retryService
 func (retryService *RetryService) Remove(db *dbhelper.DBhelper, subscriptionPK uint32, retry *models.Retry) {
-	delete(retryService.RetryList, subscriptionPK)
+	retryService.mutex.Lock()
+	//The retry might have been replaced in the meantime
+	if retryService.retryList[subscriptionPK] == retry {
+		delete(retryService.retryList, subscriptionPK)
+	}
+	retryService.mutex.Unlock()
+
 	retry.Delete(db)
 }
 
+//RemoveSubscription removes the retry of the subscription if it has one
+func (retryService *RetryService) RemoveSubscription(db *dbhelper.DBhelper, subscriptionPK uint32) bool {
+	retryService.mutex.Lock()
+	retry, has := retryService.retryList[subscriptionPK]
+	retryService.mutex.Unlock()
+
+	if has {
+		retryService.Remove(db, subscriptionPK, retry)
+	}
+	return has
+}
+
+//Return the retries which are due
+func (retryService *RetryService) getDueRetries() map[uint32]*models.Retry {
+	retryService.mutex.Lock()
+	defer retryService.mutex.Unlock()
+
+	due := make(map[uint32]*models.Retry)
+	for subsPK, retry := range retryService.retryList {
+		if retry.NextRetry.Unix() <= time.Now().Unix() {
+			due[subsPK] = retry
+		}
+	}
+	return due
+}
+
 //Start starts the retryService
 func (retryService *RetryService) Start() {
 	go (func() {
@@ -65,53 +102,87 @@ func (retryService *RetryService) Start() {
 }
 
 func (retryService *RetryService) handle() {
-	for subsPK, retry := range retryService.RetryList {
-		//If retry time is come
-		if retry.NextRetry.Unix() <= time.Now().Unix() {
-			if retry.TryNr >= uint8(len(retryService.RetryTimes)) {
-				log.Info("Removing subscription. Reason: too many retries")
-				err := models.RemoveSubscriptionByPK(retryService.db, subsPK)
-				if err != nil {
-					log.Println(err.Error())
-				}
+	for subsPK, retry := range retryService.getDueRetries() {
+		//Keep retries of paused sources until they get resumed
+		if paused, err := models.IsSourcePaused(retryService.db, retry.SourcePK); err == nil && paused {
+			continue
+		}
 
+		subscription, err := models.GetSubscriptionByPK(retryService.db, subsPK)
+		if err != nil {
+			log.Error("getSubsFromPK", err.Error())
+			if err.Error() == dbhelper.ErrNoRowsInResultSet {
+				//Subscription was removed
 				retryService.Remove(retryService.db, subsPK, retry)
-			} else {
-				retry.TryNr++
-				retryService.calcNextRetryTime(retry)
-				retry.UpdateNext(retryService.db)
-				retryService.do(subsPK, retry)
 			}
+			continue
+		}
+
+		//The first delivery was an attempt too
+		if int(retry.TryNr)+1 >= subscription.GetMaxAttempts(len(retryService.RetryTimes)+1) {
+			log.Info("Removing subscription. Reason: too many retries")
+			err := models.RemoveSubscriptionByPK(retryService.db, subsPK)
+			if err != nil {
+				log.Println(err.Error())
+			}
+
+			retryService.Remove(retryService.db, subsPK, retry)
+		} else if retryService.do(subscription, retry) {
+			retry.TryNr++
+			retryService.calcNextRetryTime(*subscription, retry)
+			retry.UpdateNext(retryService.db)
 		}
 	}
 }
 
-func (retryService *RetryService) do(subsPK uint32, retry *models.Retry) {
-	subscription, err := models.GetSubscriptionByPK(retryService.db, subsPK)
-	if err != nil {
-		log.Error("getSubsFromPK", err.Error())
-		return
-	}
+//Schedule the retry. Returns false if the delivery queue is full and the retry should be done later
+func (retryService *RetryService) do(subscription *models.Subscription, retry *models.Retry) bool {
 	source, err := models.GetSourceByPK(retryService.db, retry.SourcePK)
 	if err != nil {
 		log.Error("getSourceFromPK", err.Error())
-		return
+		return true
 	}
 	webhook, err := models.GetWebhookByPK(retryService.db, retry.WebhookPK)
 	if err != nil {
 		log.Error("getWebhookFromPK", err.Error())
-		return
+		return true
 	}
 
 	log.Debug("Doing retry")
 
-	go subscription.Notify(retryService.db, webhook, source, retryService.Callback)
+	err = retryService.Scheduler.Schedule(models.Delivery{
+		Webhook:      webhook,
+		Source:       source,
+		Subscription: subscription,
+		Ordered:      subscription.Ordered,
+	})
+	if err == models.ErrQueueFull {
+		log.Debug("Delivery queue full. Retrying later")
+		return false
+	}
+
+	return true
 }
 
-func (retryService *RetryService) calcNextRetryTime(retry *models.Retry) {
-	retry.NextRetry = retryService.getRetryTime(retry.TryNr)
+func (retryService *RetryService) calcNextRetryTime(subscription models.Subscription, retry *models.Retry) {
+	retry.NextRetry = retryService.getRetryTime(subscription, retry.TryNr)
 }
 
-func (retryService RetryService) getRetryTime(tryNr uint8) time.Time {
-	return time.Now().Add(retryService.RetryTimes[tryNr])
+//Return the time of the next retry. The backoff of the subscription overrides the RetryTimes.
+//Retries exceeding the schedule use its last step
+func (retryService *RetryService) getRetryTime(subscription models.Subscription, tryNr uint8) time.Time {
+	if backoff := subscription.GetBackoff(); len(backoff) > 0 {
+		if int(tryNr) >= len(backoff) {
+			tryNr = uint8(len(backoff) - 1)
+		}
+		return time.Now().Add(backoff[tryNr])
+	}
+
+	wait, has := retryService.RetryTimes[tryNr]
+	for !has && tryNr > 0 {
+		tryNr--
+		wait, has = retryService.RetryTimes[tryNr]
+	}
+
+	return time.Now().Add(wait)
 }
@@ -7,6 +7,7 @@ import (
 
 	dbhelper "github.com/JojiiOfficial/GoDBHelper"
 	"github.com/JojiiOfficial/WhShareServer/models"
+	"github.com/JojiiOfficial/WhShareServer/modes"
 )
 
 //ConnectDB connects to MySQL
@@ -36,7 +37,24 @@ func ConnectDB(config *models.ConfigStruct, isDebug, nocolor bool) (*dbhelper.DB
 
 func updateDB(db *dbhelper.DBhelper) error {
 	db.AddQueryChain(getInitSQL())
-	return db.RunUpdate()
+	db.AddQueryChain(getUpdateSQL())
+	if err := db.RunUpdate(); err != nil {
+		return err
+	}
+
+	if err := migrateLegacyHeaders(db); err != nil {
+		return err
+	}
+
+	if err := backfillSigningSecrets(db); err != nil {
+		return err
+	}
+
+	if err := encryptStaticHeaders(db); err != nil {
+		return err
+	}
+
+	return syncModes(db)
 }
 
 func getInitSQL() dbhelper.QueryChain {
@@ -80,9 +98,6 @@ func getInitSQL() dbhelper.QueryChain {
 				Query:   "CREATE TABLE `%s` ( `modeID` TINYINT UNSIGNED NOT NULL, `name` text NOT NULL, PRIMARY KEY (`modeID`)) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4",
 				FParams: []string{models.TableModes},
 			},
-			dbhelper.InitSQL{
-				Query: "INSERT INTO `Modes` (`modeID`, `name`) VALUES ('0', 'Custom'), ('1', 'Gitlab'), ('2', 'Docker'), ('3', 'Github')",
-			},
 			dbhelper.InitSQL{
 				//Create foreign key sources.mode -> modes.modeID
 				Query:   "ALTER TABLE `%s` ADD CONSTRAINT `%s_ibfk_2` FOREIGN KEY (`mode`) REFERENCES `%s` (`modeID`);",
@@ -121,3 +136,246 @@ func getInitSQL() dbhelper.QueryChain {
 		),
 	}
 }
+
+//Queries which update an existing database. Each query requires a new version
+func getUpdateSQL() dbhelper.QueryChain {
+	return dbhelper.QueryChain{
+		Name:  "updateChain",
+		Order: 1,
+		Queries: []dbhelper.SQLQuery{
+			//Sources: secret rotation
+			dbhelper.SQLQuery{
+				VersionAdded: 0.1,
+				FqueryString: "ALTER TABLE `%s` ADD `oldSecret` varchar(48) NOT NULL DEFAULT '' AFTER `secret`, ADD `oldSecretExpires` timestamp NULL DEFAULT NULL AFTER `oldSecret`",
+				Fparams:      []string{models.TableSources},
+			},
+			//Subscriptions: payload templates
+			dbhelper.SQLQuery{
+				VersionAdded: 0.2,
+				FqueryString: "ALTER TABLE `%s` ADD `template` text NOT NULL, ADD `templateType` varchar(255) NOT NULL DEFAULT ''",
+				Fparams:      []string{models.TableSubscriptions},
+			},
+			//Subscriptions: delivery filters
+			dbhelper.SQLQuery{
+				VersionAdded: 0.3,
+				FqueryString: "ALTER TABLE `%s` ADD `filter` text NOT NULL",
+				Fparams:      []string{models.TableSubscriptions},
+			},
+			//Event types
+			dbhelper.SQLQuery{
+				VersionAdded: 0.4,
+				FqueryString: "ALTER TABLE `%s` ADD `eventType` varchar(64) NOT NULL DEFAULT ''",
+				Fparams:      []string{models.TableWebhooks},
+			},
+			dbhelper.SQLQuery{
+				VersionAdded: 0.4,
+				FqueryString: "ALTER TABLE `%s` ADD `eventTypes` text NOT NULL",
+				Fparams:      []string{models.TableSubscriptions},
+			},
+			//Deduplication
+			dbhelper.SQLQuery{
+				VersionAdded: 0.5,
+				FqueryString: "ALTER TABLE `%s` ADD `deliveryID` varchar(128) NOT NULL DEFAULT '', ADD KEY `delivery` (`sourceID`, `deliveryID`)",
+				Fparams:      []string{models.TableWebhooks},
+			},
+			dbhelper.SQLQuery{
+				VersionAdded: 0.5,
+				FqueryString: "ALTER TABLE `%s` ADD `duplicates` int(10) unsigned NOT NULL DEFAULT '0'",
+				Fparams:      []string{models.TableSources},
+			},
+			//Sources: IP allowlist
+			dbhelper.SQLQuery{
+				VersionAdded: 0.6,
+				FqueryString: "ALTER TABLE `%s` ADD `allowedIPs` text NOT NULL",
+				Fparams:      []string{models.TableSources},
+			},
+			//Roles: webhook rate limits
+			dbhelper.SQLQuery{
+				VersionAdded: 0.7,
+				FqueryString: "ALTER TABLE `%s` ADD `maxHookRate` int(11) NOT NULL DEFAULT '0' COMMENT 'per minute', ADD `maxHookBurst` int(11) NOT NULL DEFAULT '0'",
+				Fparams:      []string{models.TableRoles},
+			},
+			//Webhook outbox
+			dbhelper.SQLQuery{
+				VersionAdded: 0.8,
+				FqueryString: "CREATE TABLE `%s` (`pk_id` int(10) unsigned NOT NULL AUTO_INCREMENT, `webhookID` int(10) unsigned NOT NULL, `created` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP, PRIMARY KEY (`pk_id`), KEY `webhookID` (`webhookID`), CONSTRAINT `%s_ibfk_1` FOREIGN KEY (`webhookID`) REFERENCES `%s` (`pk_id`) ON DELETE CASCADE) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4",
+				Fparams:      []string{models.TableOutbox, models.TableOutbox, models.TableWebhooks},
+			},
+			//Sources: redaction rules
+			dbhelper.SQLQuery{
+				VersionAdded: 0.9,
+				FqueryString: "ALTER TABLE `%s` ADD `redaction` text NOT NULL AFTER `allowedIPs`",
+				Fparams:      []string{models.TableSources},
+			},
+			//Webhooks: request method and query
+			dbhelper.SQLQuery{
+				VersionAdded: 1.0,
+				FqueryString: "ALTER TABLE `%s` ADD `method` varchar(10) NOT NULL DEFAULT 'POST' AFTER `header`, ADD `query` text NOT NULL AFTER `method`",
+				Fparams:      []string{models.TableWebhooks},
+			},
+			//Synchronous relay
+			dbhelper.SQLQuery{
+				VersionAdded: 1.1,
+				FqueryString: "ALTER TABLE `%s` ADD `syncSubscription` int(10) unsigned NOT NULL DEFAULT '0'",
+				Fparams:      []string{models.TableSources},
+			},
+			dbhelper.SQLQuery{
+				VersionAdded: 1.1,
+				FqueryString: "ALTER TABLE `%s` ADD `excludedSubscription` int(10) unsigned NOT NULL DEFAULT '0'",
+				Fparams:      []string{models.TableOutbox},
+			},
+			//Sources: pause delivery
+			dbhelper.SQLQuery{
+				VersionAdded: 1.2,
+				FqueryString: "ALTER TABLE `%s` ADD `paused` tinyint(1) NOT NULL DEFAULT '0'",
+				Fparams:      []string{models.TableSources},
+			},
+			//Delivery attempts
+			dbhelper.SQLQuery{
+				VersionAdded: 1.3,
+				FqueryString: "CREATE TABLE `%s` (`pk_id` int(10) unsigned NOT NULL AUTO_INCREMENT, `webhookID` int(10) unsigned NOT NULL, `subscriptionID` int(10) unsigned NOT NULL, `attempt` smallint(5) unsigned NOT NULL, `isReplay` tinyint(1) NOT NULL DEFAULT '0', `statusCode` int(11) NOT NULL DEFAULT '0', `latency` int(10) unsigned NOT NULL DEFAULT '0' COMMENT 'in ms', `error` text NOT NULL, `responseBody` text NOT NULL, `created` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP, PRIMARY KEY (`pk_id`), KEY `webhookID` (`webhookID`, `subscriptionID`), KEY `subscriptionID` (`subscriptionID`), CONSTRAINT `%s_ibfk_1` FOREIGN KEY (`subscriptionID`) REFERENCES `%s` (`pk_id`) ON DELETE CASCADE) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4",
+				Fparams:      []string{models.TableDeliveryAttempts, models.TableDeliveryAttempts, models.TableSubscriptions},
+			},
+			//Sources: JSON schema validation
+			dbhelper.SQLQuery{
+				VersionAdded: 1.4,
+				FqueryString: "ALTER TABLE `%s` ADD `jsonSchema` text NOT NULL, ADD `validationFailures` int(10) unsigned NOT NULL DEFAULT '0'",
+				Fparams:      []string{models.TableSources},
+			},
+			//Subscriptions: signing secrets. Existing subscriptions get one from backfillSigningSecrets
+			dbhelper.SQLQuery{
+				VersionAdded: 1.5,
+				FqueryString: "ALTER TABLE `%s` ADD `signingSecret` varchar(48) NOT NULL DEFAULT '', ADD `oldSigningSecret` varchar(48) NOT NULL DEFAULT '', ADD `oldSigningSecretExpires` timestamp NULL DEFAULT NULL",
+				Fparams:      []string{models.TableSubscriptions},
+			},
+			//Subscriptions: request customization
+			dbhelper.SQLQuery{
+				VersionAdded: 1.6,
+				FqueryString: "ALTER TABLE `%s` ADD `method` varchar(10) NOT NULL DEFAULT '', ADD `headers` text NOT NULL, ADD `auth` text NOT NULL, ADD `authType` varchar(10) NOT NULL DEFAULT ''",
+				Fparams:      []string{models.TableSubscriptions},
+			},
+			//Delivery timeout and retry policy
+			dbhelper.SQLQuery{
+				VersionAdded: 1.7,
+				FqueryString: "ALTER TABLE `%s` ADD `timeout` int(10) unsigned NOT NULL DEFAULT '0' COMMENT 'in s', ADD `maxAttempts` tinyint(3) unsigned NOT NULL DEFAULT '0', ADD `backoff` text NOT NULL",
+				Fparams:      []string{models.TableSubscriptions},
+			},
+			dbhelper.SQLQuery{
+				VersionAdded: 1.7,
+				FqueryString: "ALTER TABLE `%s` ADD `maxDeliveryTimeout` int(11) NOT NULL DEFAULT '0' COMMENT 'in s', ADD `maxRetryAttempts` int(11) NOT NULL DEFAULT '0', ADD `minRetryBackoff` int(11) NOT NULL DEFAULT '0' COMMENT 'in s'",
+				Fparams:      []string{models.TableRoles},
+			},
+			//Subscriptions: ordered delivery
+			dbhelper.SQLQuery{
+				VersionAdded: 1.8,
+				FqueryString: "ALTER TABLE `%s` ADD `ordered` tinyint(1) NOT NULL DEFAULT '0'",
+				Fparams:      []string{models.TableSubscriptions},
+			},
+			dbhelper.SQLQuery{
+				VersionAdded: 1.8,
+				FqueryString: "CREATE TABLE `%s` (`pk_id` int(10) unsigned NOT NULL AUTO_INCREMENT, `subscriptionID` int(10) unsigned NOT NULL, `webhookID` int(10) unsigned NOT NULL, `created` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP, PRIMARY KEY (`pk_id`), UNIQUE KEY `subscriptionWebhook` (`subscriptionID`, `webhookID`), KEY `webhookID` (`webhookID`), CONSTRAINT `%s_ibfk_1` FOREIGN KEY (`subscriptionID`) REFERENCES `%s` (`pk_id`) ON DELETE CASCADE, CONSTRAINT `%s_ibfk_2` FOREIGN KEY (`webhookID`) REFERENCES `%s` (`pk_id`) ON DELETE CASCADE) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4",
+				Fparams:      []string{models.TableOrderedQueue, models.TableOrderedQueue, models.TableSubscriptions, models.TableOrderedQueue, models.TableWebhooks},
+			},
+			//Deduplication: claim delivery IDs with a unique key. Content hashes are opt-in
+			dbhelper.SQLQuery{
+				VersionAdded: 1.9,
+				FqueryString: "CREATE TABLE `%s` (`sourceID` int(10) unsigned NOT NULL, `deliveryID` varchar(128) NOT NULL, `received` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP, PRIMARY KEY (`sourceID`, `deliveryID`), KEY `received` (`received`), CONSTRAINT `%s_ibfk_1` FOREIGN KEY (`sourceID`) REFERENCES `%s` (`pk_id`) ON DELETE CASCADE) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4",
+				Fparams:      []string{models.TableDeliveryIDs, models.TableDeliveryIDs, models.TableSources},
+			},
+			dbhelper.SQLQuery{
+				VersionAdded: 1.9,
+				FqueryString: "ALTER TABLE `%s` ADD `hashDeduplication` tinyint(1) NOT NULL DEFAULT '0'",
+				Fparams:      []string{models.TableSources},
+			},
+		},
+	}
+}
+
+//migrateLegacyHeaders converts headers stored in the key=value format to JSON
+func migrateLegacyHeaders(db *dbhelper.DBhelper) error {
+	var webhooks []models.Webhook
+	err := db.QueryRowsf(&webhooks, "SELECT * FROM %s WHERE header NOT LIKE '{%%'", []string{models.TableWebhooks})
+	if err != nil {
+		return err
+	}
+
+	for _, webhook := range webhooks {
+		_, err = db.Execf("UPDATE %s SET header=? WHERE pk_id=?", []string{models.TableWebhooks}, models.EncodeHeader(webhook.GetHeader()), webhook.PkID)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(webhooks) > 0 {
+		log.Infof("Migrated headers of %d webhooks\n", len(webhooks))
+	}
+
+	return nil
+}
+
+//backfillSigningSecrets generates a signing secret for each subscription without one
+func backfillSigningSecrets(db *dbhelper.DBhelper) error {
+	var subscriptions []models.Subscription
+	err := db.QueryRowsf(&subscriptions, "SELECT * FROM %s WHERE signingSecret=''", []string{models.TableSubscriptions})
+	if err != nil {
+		return err
+	}
+
+	for _, subscription := range subscriptions {
+		secret, err := models.NewSigningSecret()
+		if err != nil {
+			return err
+		}
+
+		_, err = db.Execf("UPDATE %s SET signingSecret=? WHERE pk_id=?", []string{models.TableSubscriptions}, secret, subscription.PkID)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(subscriptions) > 0 {
+		log.Infof("Generated signing secrets for %d subscriptions\n", len(subscriptions))
+	}
+
+	return nil
+}
+
+//encryptStaticHeaders encrypts static headers of subscriptions stored in plaintext
+func encryptStaticHeaders(db *dbhelper.DBhelper) error {
+	var subscriptions []models.Subscription
+	err := db.QueryRowsf(&subscriptions, "SELECT * FROM %s WHERE headers LIKE '{%%'", []string{models.TableSubscriptions})
+	if err != nil || len(subscriptions) == 0 {
+		return err
+	}
+
+	if !models.HasEncryptionKey() {
+		log.Warnf("%d subscriptions have unencrypted headers. Set an EncryptionKey to encrypt them\n", len(subscriptions))
+		return nil
+	}
+
+	for _, subscription := range subscriptions {
+		headers, err := subscription.GetStaticHeaders()
+		if err != nil {
+			return err
+		}
+
+		if err = subscription.UpdateHeaders(db, headers); err != nil {
+			return err
+		}
+	}
+
+	log.Infof("Encrypted headers of %d subscriptions\n", len(subscriptions))
+	return nil
+}
+
+//syncModes stores all registered modes in the DB
+func syncModes(db *dbhelper.DBhelper) error {
+	for _, mode := range modes.All() {
+		_, err := db.Execf("INSERT INTO %s (modeID, name) VALUES (?,?) ON DUPLICATE KEY UPDATE name=?", []string{models.TableModes}, mode.ID(), mode.Name(), mode.Name())
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}